@@ -0,0 +1,6 @@
+data "swarm_join_tokens" "cluster" {
+}
+
+locals {
+  join_worker = "docker swarm join --token ${data.swarm_join_tokens.cluster.worker_token} ${data.swarm_join_tokens.cluster.manager_addrs[0]}"
+}
@@ -1,2 +1,17 @@
 data "swarm_nodes" "local_nodes" {
-}
\ No newline at end of file
+}
+
+data "swarm_nodes" "workers" {
+  filter {
+    role         = "worker"
+    availability = "active"
+
+    labels = {
+      zone = "a"
+    }
+  }
+}
+
+output "worker_zones" {
+  value = { for node in data.swarm_nodes.workers.all : node.hostname => node.node_labels["zone"] }
+}
@@ -4,4 +4,18 @@ provider "swarm" {
 
   // ssh_user = "terraform
   // ssh_key = "$HOME/.ssh/terraform_rsa""
+  // ssh_agent = true
+  // host_key_policy = "strict"
+  // known_hosts_file = "$HOME/.ssh/known_hosts"
+  // host_keys = {
+  //   "10.0.0.1" = "SHA256:..."
+  // }
+
+  // bastion_host = "bastion.example.com"
+  // bastion_user = "jump"
+
+  // docker_host = "tcp://manager1.example.com:2376"
+  // ca_cert     = "$HOME/.docker/ca.pem"
+  // client_cert = "$HOME/.docker/cert.pem"
+  // client_key  = "$HOME/.docker/key.pem"
 }
\ No newline at end of file
@@ -0,0 +1,11 @@
+resource "time_rotating" "root_ca" {
+  rotation_days = 365
+}
+
+resource "swarm_ca" "root" {
+  rotation_timeout = "30m"
+
+  triggers = {
+    rotation = time_rotating.root_ca.id
+  }
+}
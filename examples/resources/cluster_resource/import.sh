@@ -0,0 +1,5 @@
+# Import an existing swarm cluster through one of its manager nodes
+terraform import swarm_cluster.cluster 10.0.0.10
+
+# Optionally assert the cluster ID of the swarm being imported
+terraform import swarm_cluster.cluster um5m2mo3nmi10c8kyh733bafv/10.0.0.10
@@ -6,5 +6,8 @@ resource "swarm_cluster" "local_cluster" {
     tags = {
       role = "manager"
     }
+    labels = {
+      zone = "a"
+    }
   }
 }
\ No newline at end of file
@@ -0,0 +1,2 @@
+# Import the settings of the swarm cluster by its cluster ID
+terraform import swarm_cluster_spec.settings um5m2mo3nmi10c8kyh733bafv
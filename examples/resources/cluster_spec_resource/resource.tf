@@ -0,0 +1,11 @@
+resource "swarm_cluster_spec" "settings" {
+  task_history_limit   = 10
+  snapshot_interval    = 10000
+  dispatcher_heartbeat = "10s"
+  cert_expiry          = "720h"
+  autolock             = true
+
+  unlock_key_triggers = {
+    rotation = "2021-10"
+  }
+}
@@ -0,0 +1,2 @@
+# Import an existing swarm config by its ID or name
+terraform import swarm_config.example example
@@ -0,0 +1,13 @@
+resource "swarm_config" "example" {
+  name        = "example"
+  data        = file("${path.module}/nginx.conf")
+  append_hash = true
+
+  labels = {
+    team = "platform"
+  }
+
+  lifecycle {
+    create_before_destroy = true
+  }
+}
@@ -0,0 +1,12 @@
+resource "time_rotating" "join_tokens" {
+  rotation_days = 30
+}
+
+resource "swarm_join_token_rotation" "monthly" {
+  rotate_manager_token = true
+  rotate_worker_token  = true
+
+  triggers = {
+    rotation = time_rotating.join_tokens.id
+  }
+}
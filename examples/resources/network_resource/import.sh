@@ -0,0 +1,2 @@
+# Import an existing swarm network by its ID or name
+terraform import swarm_network.backend backend
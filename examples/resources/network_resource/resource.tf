@@ -0,0 +1,15 @@
+resource "swarm_network" "backend" {
+  name       = "backend"
+  driver     = "overlay"
+  attachable = true
+  encrypted  = true
+
+  ipam_config {
+    subnet  = "10.10.0.0/24"
+    gateway = "10.10.0.1"
+  }
+
+  labels = {
+    team = "platform"
+  }
+}
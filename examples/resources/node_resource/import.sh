@@ -0,0 +1,2 @@
+# Import an existing swarm node by its ID or hostname
+terraform import swarm_node.worker1 worker1
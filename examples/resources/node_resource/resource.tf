@@ -0,0 +1,8 @@
+resource "swarm_node" "worker1" {
+  node         = "worker1"
+  availability = "drain"
+
+  labels = {
+    zone = "a"
+  }
+}
@@ -0,0 +1,13 @@
+resource "swarm_secret" "example" {
+  name        = "example"
+  data        = var.db_password
+  append_hash = true
+
+  labels = {
+    team = "platform"
+  }
+
+  lifecycle {
+    create_before_destroy = true
+  }
+}
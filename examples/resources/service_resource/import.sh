@@ -0,0 +1,2 @@
+# Import an existing swarm service by its ID or name
+terraform import swarm_service.web web
@@ -0,0 +1,44 @@
+resource "swarm_service" "web" {
+  name     = "web"
+  image    = "nginx:1.21"
+  replicas = 3
+
+  env = {
+    NGINX_PORT = "80"
+  }
+
+  labels = {
+    team = "platform"
+  }
+
+  ports {
+    target_port    = 80
+    published_port = 8080
+  }
+
+  mounts {
+    type   = "volume"
+    source = "web-data"
+    target = "/usr/share/nginx/html"
+  }
+
+  constraints           = ["node.role==worker"]
+  placement_preferences = ["spread=node.labels.zone"]
+
+  resources {
+    limit_cpus   = 0.5
+    limit_memory = 268435456
+  }
+
+  update_config {
+    parallelism = 1
+    delay       = "10s"
+    order       = "start-first"
+  }
+
+  healthcheck {
+    command  = "curl -f http://localhost/ || exit 1"
+    interval = "30s"
+    retries  = 3
+  }
+}
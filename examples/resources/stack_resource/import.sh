@@ -0,0 +1,2 @@
+# Import an existing swarm stack by its name
+terraform import swarm_stack.web web
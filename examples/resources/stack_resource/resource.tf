@@ -0,0 +1,9 @@
+resource "swarm_stack" "web" {
+  name         = "web"
+  compose_file = "${path.module}/docker-compose.yml"
+  prune        = true
+
+  environment = {
+    NGINX_VERSION = "1.21"
+  }
+}
@@ -3,10 +3,14 @@ module github.com/aucloud/terraform-provider-swarm
 go 1.17
 
 require (
+	github.com/aucloud/go-runcmd v0.0.0-20220111143825-aaec1329e918
 	github.com/aucloud/go-swarm v0.0.0-20220315114454-382fc6f83fd1
+	github.com/hashicorp/go-cty v1.4.1-0.20200414143053-d3edf31b6320
 	github.com/hashicorp/terraform-plugin-docs v0.5.1
 	github.com/hashicorp/terraform-plugin-sdk/v2 v2.9.0
 	github.com/sirupsen/logrus v1.8.1
+	golang.org/x/crypto v0.0.0-20220314234724-5d542ad81a58
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -19,7 +23,6 @@ require (
 	github.com/anmitsu/go-shlex v0.0.0-20200514113438-38f4b401e2be // indirect
 	github.com/apparentlymart/go-textseg/v13 v13.0.0 // indirect
 	github.com/armon/go-radix v1.0.0 // indirect
-	github.com/aucloud/go-runcmd v0.0.0-20220111143825-aaec1329e918 // indirect
 	github.com/aucloud/go-sshutil v0.0.0-20220111080955-99a36586cfcc // indirect
 	github.com/aws/aws-sdk-go v1.25.3 // indirect
 	github.com/bgentry/go-netrc v0.0.0-20140422174119-9fd32a8b3d3d // indirect
@@ -33,7 +36,6 @@ require (
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-checkpoint v0.5.0 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
-	github.com/hashicorp/go-cty v1.4.1-0.20200414143053-d3edf31b6320 // indirect
 	github.com/hashicorp/go-getter v1.5.3 // indirect
 	github.com/hashicorp/go-hclog v1.0.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
@@ -70,7 +72,6 @@ require (
 	go.fuchsia.dev/fuchsia/tools v0.0.0-20210227002403-8023e94b8b78 // indirect
 	go.mills.io/jsonlines v0.0.0-20211103061136-4304f35d60a8 // indirect
 	go.opencensus.io v0.23.0 // indirect
-	golang.org/x/crypto v0.0.0-20220314234724-5d542ad81a58 // indirect
 	golang.org/x/net v0.0.0-20211123203042-d83791d6bcd9 // indirect
 	golang.org/x/oauth2 v0.0.0-20210819190943-2bc19b11175f // indirect
 	golang.org/x/sys v0.0.0-20220310020820-b874c991c1a5 // indirect
@@ -0,0 +1,221 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aucloud/go-swarm"
+)
+
+// clusterChanges is the set of changes required to move a swarm cluster
+// from one set of nodes to another.
+type clusterChanges struct {
+	join    swarm.VMNodes
+	promote swarm.VMNodes
+	demote  swarm.VMNodes
+	remove  swarm.VMNodes
+
+	// kept are the managers that remain managers throughout the update
+	kept swarm.VMNodes
+
+	// nodes are all of the nodes of the cluster after the update and
+	// previous all of them before
+	nodes    swarm.VMNodes
+	previous swarm.VMNodes
+}
+
+// diffVMNodes computes the changes between the old and new nodes of a swarm
+// cluster. Nodes are matched by hostname.
+func diffVMNodes(oldNodes, newNodes swarm.VMNodes) clusterChanges {
+	changes := clusterChanges{nodes: newNodes, previous: oldNodes}
+
+	current := make(map[string]swarm.VMNode)
+	for _, vm := range oldNodes {
+		current[vm.Hostname] = vm
+	}
+
+	desired := make(map[string]bool)
+	for _, vm := range newNodes {
+		desired[vm.Hostname] = true
+
+		old, ok := current[vm.Hostname]
+		if !ok {
+			changes.join = append(changes.join, vm)
+			continue
+		}
+
+		wasManager := old.HasTag(swarm.RoleTag, swarm.ManagerRole)
+		isManager := vm.HasTag(swarm.RoleTag, swarm.ManagerRole)
+
+		switch {
+		case wasManager && isManager:
+			changes.kept = append(changes.kept, vm)
+		case !wasManager && isManager:
+			changes.promote = append(changes.promote, vm)
+		case wasManager && !isManager:
+			changes.demote = append(changes.demote, vm)
+		}
+	}
+
+	for _, vm := range oldNodes {
+		if !desired[vm.Hostname] {
+			changes.remove = append(changes.remove, vm)
+		}
+	}
+
+	return changes
+}
+
+// lostManagers returns the number of current managers that are either
+// demoted or removed from the cluster.
+func (c clusterChanges) lostManagers() int {
+	return len(c.demote) + len(c.remove.FilterByTag(swarm.RoleTag, swarm.ManagerRole))
+}
+
+// validateQuorum ensures that the changes can be applied to a cluster with
+// the given number of managers without it ever losing raft quorum.
+func (c clusterChanges) validateQuorum(managers int) error {
+	// A raft cluster of N managers tolerates the loss of (N-1)/2 managers
+	tolerance := (managers - 1) / 2
+
+	if lost := c.lostManagers(); lost > tolerance {
+		return fmt.Errorf(
+			"error demoting or removing %d of %d managers would lose quorum, at most %d can be demoted or removed at a time",
+			lost, managers, tolerance,
+		)
+	}
+
+	if len(c.kept) == 0 {
+		return fmt.Errorf("error at least one existing manager must remain a manager")
+	}
+
+	return nil
+}
+
+// apply applies the changes to the swarm cluster via the given manager.
+// New nodes are joined and promoted before any manager is demoted or node
+// removed so the number of managers never drops below what is required.
+// Removed nodes are drained first unless drainTimeout is zero. Finally the
+// labels of every remaining node are reconciled.
+func (c clusterChanges) apply(swarmManager *swarm.Manager, manager swarm.VMNode, drainTimeout time.Duration) error {
+	if err := switchNode(swarmManager, manager.PublicAddress); err != nil {
+		return fmt.Errorf("error switching to manager node %s: %w", manager.Hostname, err)
+	}
+
+	nodes, err := getNodes(swarmManager)
+	if err != nil {
+		return fmt.Errorf("error listing nodes: %w", err)
+	}
+
+	members := make(map[string]Node)
+	for _, node := range nodes {
+		members[node.Description.Hostname] = node
+	}
+
+	if len(c.join) > 0 {
+		managerToken, err := swarmManager.JoinToken(swarm.ManagerRole)
+		if err != nil {
+			return fmt.Errorf("error getting manager join token: %w", err)
+		}
+
+		workerToken, err := swarmManager.JoinToken(swarm.WorkerRole)
+		if err != nil {
+			return fmt.Errorf("error getting worker join token: %w", err)
+		}
+
+		joins := append(
+			c.join.FilterByTag(swarm.RoleTag, swarm.ManagerRole),
+			c.join.FilterByTag(swarm.RoleTag, swarm.WorkerRole)...,
+		)
+
+		for _, vm := range joins {
+			if node, ok := members[vm.Hostname]; ok {
+				if node.Status.State != nodeStateDown {
+					// Already part of the cluster
+					continue
+				}
+
+				// Remove the stale entry of a node that left the cluster. Docker
+				// refuses to remove a manager that has not been demoted.
+				if node.Spec.Role == swarm.ManagerRole {
+					if _, err := runCmd(swarmManager, fmt.Sprintf(demoteCommand, node.ID)); err != nil {
+						return fmt.Errorf("error demoting stale node %s: %w", vm.Hostname, err)
+					}
+				}
+				if _, err := runCmd(swarmManager, fmt.Sprintf(removeCommand, node.ID)); err != nil {
+					return fmt.Errorf("error removing stale node %s: %w", vm.Hostname, err)
+				}
+			}
+
+			token := workerToken
+			if vm.HasTag(swarm.RoleTag, swarm.ManagerRole) {
+				token = managerToken
+			}
+
+			if err := joinNode(swarmManager, vm, manager, token); err != nil {
+				return fmt.Errorf("error joining node %s: %w", vm.Hostname, err)
+			}
+
+			if err := switchNode(swarmManager, manager.PublicAddress); err != nil {
+				return fmt.Errorf("error switching to manager node %s: %w", manager.Hostname, err)
+			}
+		}
+	}
+
+	for _, vm := range c.promote {
+		if _, err := runCmd(swarmManager, fmt.Sprintf(promoteCommand, vm.Hostname)); err != nil {
+			return fmt.Errorf("error promoting node %s: %w", vm.Hostname, err)
+		}
+	}
+
+	// Managers are demoted one at a time
+	for _, vm := range c.demote {
+		if _, err := runCmd(swarmManager, fmt.Sprintf(demoteCommand, vm.Hostname)); err != nil {
+			return fmt.Errorf("error demoting node %s: %w", vm.Hostname, err)
+		}
+	}
+
+	removals := append(
+		c.remove.FilterByTag(swarm.RoleTag, swarm.WorkerRole),
+		c.remove.FilterByTag(swarm.RoleTag, swarm.ManagerRole)...,
+	)
+
+	for _, vm := range removals {
+		node, ok := members[vm.Hostname]
+		if !ok {
+			continue
+		}
+
+		// The node may still be a manager if it went down before it could be
+		// demoted by a previous apply
+		demote := vm.HasTag(swarm.RoleTag, swarm.ManagerRole) || node.Spec.Role == swarm.ManagerRole
+		if err := removeNode(swarmManager, manager, vm, node, demote, drainTimeout); err != nil {
+			return fmt.Errorf("error removing node %s: %w", vm.Hostname, err)
+		}
+	}
+
+	if err := switchNode(swarmManager, manager.PublicAddress); err != nil {
+		return fmt.Errorf("error switching to manager node %s: %w", manager.Hostname, err)
+	}
+
+	return reconcileLabels(swarmManager, c.previous, c.nodes)
+}
@@ -0,0 +1,207 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aucloud/go-swarm"
+)
+
+func testVMNode(hostname, role string) swarm.VMNode {
+	return swarm.VMNode{Hostname: hostname, Tags: map[string]string{swarm.RoleTag: role}}
+}
+
+func vmHostnames(vmnodes swarm.VMNodes) []string {
+	var names []string
+	for _, vm := range vmnodes {
+		names = append(names, vm.Hostname)
+	}
+	return names
+}
+
+func TestDiffVMNodes(t *testing.T) {
+	oldNodes := swarm.VMNodes{
+		testVMNode("manager1", swarm.ManagerRole),
+		testVMNode("manager2", swarm.ManagerRole),
+		testVMNode("manager3", swarm.ManagerRole),
+		testVMNode("worker1", swarm.WorkerRole),
+		testVMNode("worker2", swarm.WorkerRole),
+	}
+	newNodes := swarm.VMNodes{
+		testVMNode("manager1", swarm.ManagerRole),
+		testVMNode("manager2", swarm.WorkerRole),
+		testVMNode("worker1", swarm.ManagerRole),
+		testVMNode("worker2", swarm.WorkerRole),
+		testVMNode("worker3", swarm.WorkerRole),
+	}
+
+	changes := diffVMNodes(oldNodes, newNodes)
+
+	for _, tc := range []struct {
+		name     string
+		actual   swarm.VMNodes
+		expected []string
+	}{
+		{"join", changes.join, []string{"worker3"}},
+		{"promote", changes.promote, []string{"worker1"}},
+		{"demote", changes.demote, []string{"manager2"}},
+		{"remove", changes.remove, []string{"manager3"}},
+		{"kept", changes.kept, []string{"manager1"}},
+		{"nodes", changes.nodes, vmHostnames(newNodes)},
+		{"previous", changes.previous, vmHostnames(oldNodes)},
+	} {
+		if actual := vmHostnames(tc.actual); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("expected %s %q got %q", tc.name, tc.expected, actual)
+		}
+	}
+
+	if changes := diffVMNodes(oldNodes, oldNodes); len(changes.join)+len(changes.promote)+len(changes.demote)+len(changes.remove) != 0 {
+		t.Errorf("expected no changes between identical nodes got %+v", changes)
+	}
+}
+
+func TestValidateQuorum(t *testing.T) {
+	managers := swarm.VMNodes{
+		testVMNode("manager1", swarm.ManagerRole),
+		testVMNode("manager2", swarm.ManagerRole),
+		testVMNode("manager3", swarm.ManagerRole),
+		testVMNode("manager4", swarm.ManagerRole),
+		testVMNode("manager5", swarm.ManagerRole),
+	}
+	worker := func(vm swarm.VMNode) swarm.VMNode {
+		return testVMNode(vm.Hostname, swarm.WorkerRole)
+	}
+
+	testCases := []struct {
+		name     string
+		oldNodes swarm.VMNodes
+		newNodes swarm.VMNodes
+		valid    bool
+	}{
+		{
+			name:     "remove one of three managers",
+			oldNodes: managers[:3],
+			newNodes: managers[:2],
+			valid:    true,
+		},
+		{
+			name:     "demote one of three managers",
+			oldNodes: managers[:3],
+			newNodes: swarm.VMNodes{managers[0], managers[1], worker(managers[2])},
+			valid:    true,
+		},
+		{
+			name:     "remove two of three managers",
+			oldNodes: managers[:3],
+			newNodes: managers[:1],
+		},
+		{
+			name:     "demote and remove two of five managers",
+			oldNodes: managers,
+			newNodes: swarm.VMNodes{managers[0], managers[1], managers[2], worker(managers[3])},
+			valid:    true,
+		},
+		{
+			name:     "remove three of five managers",
+			oldNodes: managers,
+			newNodes: managers[:2],
+		},
+		{
+			name:     "replace every manager",
+			oldNodes: managers[:1],
+			newNodes: swarm.VMNodes{managers[1]},
+		},
+		{
+			name:     "add managers",
+			oldNodes: managers[:1],
+			newNodes: managers[:3],
+			valid:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := diffVMNodes(tc.oldNodes, tc.newNodes).validateQuorum(len(tc.oldNodes))
+			if tc.valid && err != nil {
+				t.Errorf("expected changes to be valid got %s", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("expected changes to be invalid")
+			}
+		})
+	}
+}
+
+func TestClusterChangesApplyDownManager(t *testing.T) {
+	manager1 := testNode("m1", "manager1", "10.0.0.1", swarm.ManagerRole)
+	manager2 := testNode("m2", "manager2", "10.0.0.2", swarm.ManagerRole)
+	manager3 := testNode("m3", "manager3", "10.0.0.3", swarm.ManagerRole)
+	manager3.Status.State = nodeStateDown
+	manager3.ManagerStatus.Reachability = "unreachable"
+
+	testCases := []struct {
+		name     string
+		oldNodes []Node
+		newNodes []Node
+		rejoined bool
+	}{
+		{name: "removed", oldNodes: []Node{manager1, manager2, manager3}, newNodes: []Node{manager1, manager2}},
+		{
+			// A stale entry is left behind when the node was lost along with its state
+			name:     "rejoined",
+			oldNodes: []Node{manager1, manager2},
+			newNodes: []Node{manager1, manager2, manager3},
+			rejoined: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			switcher := newTestSwitcher(manager1, manager2, manager3)
+			switcher.unreachable["10.0.0.3"] = !tc.rejoined
+			swarmManager, err := swarm.NewManager(switcher)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			oldNodes := expandVMNodes(testClusterNodes(tc.oldNodes...))
+			newNodes := expandVMNodes(testClusterNodes(tc.newNodes...))
+			changes := diffVMNodes(oldNodes, newNodes)
+
+			if err := changes.apply(swarmManager, newNodes[0], 0); err != nil {
+				t.Fatalf("expected changes to be applied got %s", err)
+			}
+
+			demoted := switcher.ran("10.0.0.1", "docker node demote m3")
+			removed := switcher.ran("10.0.0.1", "docker node rm --force m3")
+			if demoted == -1 || removed < demoted {
+				t.Errorf("expected down manager to be demoted before it is removed got %q", switcher.commands)
+			}
+			if switcher.ran("10.0.0.3", "docker swarm leave") != -1 {
+				t.Errorf("expected down manager not to leave got %q", switcher.commands)
+			}
+			if joined := switcher.ran("10.0.0.3", "docker swarm join"); tc.rejoined && joined < removed {
+				t.Errorf("expected manager to rejoin after its stale entry is removed got %q", switcher.commands)
+			}
+		})
+	}
+}
@@ -0,0 +1,601 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aucloud/go-swarm"
+)
+
+const (
+	leaveCommand      = `docker swarm leave`
+	forceLeaveCommand = `docker swarm leave --force`
+	joinCommand       = `docker swarm join --advertise-addr %s --listen-addr %s --token %s %s:2377`
+	promoteCommand    = `docker node promote %s`
+	demoteCommand     = `docker node demote %s`
+	removeCommand     = `docker node rm --force %s`
+	inspectCommand    = `docker node inspect --format "{{ json . }}" %s`
+	drainCommand      = `docker node update --availability drain %s`
+	tasksCommand      = `docker node ps --format "{{ json . }}" %s`
+	nodeUpdateCommand = `docker node update %s %s`
+
+	rotateTokenCommand     = `docker swarm join-token --rotate --quiet %s`
+	clusterInspectCommand  = `docker info --format "{{ json .Swarm.Cluster }}"`
+	clusterUpdateCommand   = `docker swarm update %s`
+	unlockKeyCommand       = `docker swarm unlock-key --quiet`
+	rotateUnlockKeyCommand = `docker swarm unlock-key --rotate --quiet`
+	unlockCommand          = `docker swarm unlock`
+	caRotateCommand        = `docker swarm ca --rotate --detach --quiet %s`
+	tempDirCommand         = `mktemp -d`
+	writeFileCommand       = `sh -c 'umask 077 && cat > "$1"' - %s`
+	removeDirCommand       = `rm -rf %s`
+
+	serviceCreateCommand  = `docker service create --detach --quiet %s`
+	serviceUpdateCommand  = `docker service update --detach --quiet %s %s`
+	serviceInspectCommand = `docker service inspect --format "{{ json . }}" %s`
+	serviceRemoveCommand  = `docker service rm %s`
+	networkNameCommand    = `docker network inspect --format "{{ .Name }}" %s`
+	networkCreateCommand  = `docker network create %s`
+	networkInspectCommand = `docker network inspect --format "{{ json . }}" %s`
+	networkRemoveCommand  = `docker network rm %s`
+
+	stackDeployCommand   = `docker stack deploy --compose-file - %s`
+	stackServicesCommand = `docker stack services --format "{{ json . }}" %s`
+	stackRemoveCommand   = `docker stack rm %s`
+
+	// Secrets and configs share the same commands
+	objectCreateCommand  = `docker %s create %s -`
+	objectInspectCommand = `docker %s inspect --format "{{ json . }}" %s`
+	objectRemoveCommand  = `docker %s rm %s`
+
+	caRotationPollInterval = time.Second * 5
+)
+
+// drainPollInterval is how often the tasks of a draining node are checked
+var drainPollInterval = time.Second * 5
+
+// runCmd runs cmd on the node the swarm manager is currently switched to
+// and returns its standard output. It mirrors the unexported helper of the
+// same name in go-swarm for the commands the library does not expose.
+func runCmd(swarmManager *swarm.Manager, cmd string) (io.Reader, error) {
+	return runCmdWithInput(swarmManager, cmd, nil)
+}
+
+// runCmdWithInput runs cmd like runCmd and writes stdin to its standard
+// input. This keeps sensitive data such as secrets off the command line.
+func runCmdWithInput(swarmManager *swarm.Manager, cmd string, stdin []byte) (io.Reader, error) {
+	if swarmManager.Runner() == nil {
+		return nil, fmt.Errorf("error no runner configured")
+	}
+
+	worker, err := swarmManager.Runner().Command(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error creating worker: %w", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	worker.SetStdout(stdout)
+
+	stderr := &bytes.Buffer{}
+	worker.SetStderr(stderr)
+
+	var input io.WriteCloser
+	if stdin != nil {
+		input, err = worker.StdinPipe()
+		if err != nil {
+			return nil, fmt.Errorf("error opening stdin: %w", err)
+		}
+	}
+
+	if err := worker.Start(); err != nil {
+		return nil, fmt.Errorf("error starting worker: %w", err)
+	}
+
+	if input != nil {
+		if _, err := input.Write(stdin); err != nil {
+			return nil, fmt.Errorf("error writing stdin: %w", err)
+		}
+		if err := input.Close(); err != nil {
+			return nil, fmt.Errorf("error closing stdin: %w", err)
+		}
+	}
+
+	if err := worker.Wait(); err != nil {
+		return nil, fmt.Errorf(
+			"error running worker: %w (stderr=%q stdout=%q)",
+			err, stderr.String(), stdout.String(),
+		)
+	}
+
+	return stdout, nil
+}
+
+// ensureManager ensures the swarm manager is switched to a manager node by
+// jumping to one of the remote managers if the current node is a worker.
+func ensureManager(swarmManager *swarm.Manager) error {
+	if swarmManager.Runner() == nil {
+		return fmt.Errorf("error not connected to any node, please configure ssh_addr or use_local")
+	}
+
+	node, err := swarmManager.GetInfo()
+	if err != nil {
+		return fmt.Errorf("error getting node info: %w", err)
+	}
+
+	if node.IsManager() {
+		return nil
+	}
+
+	for _, remoteManager := range node.Swarm.RemoteManagers {
+		host, _, err := net.SplitHostPort(remoteManager.Addr)
+		if err != nil {
+			continue
+		}
+		if err := swarmManager.SwitchNodeVia(host); err != nil {
+			continue
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unable to connect to suitable manager")
+}
+
+// switchNode switches the swarm manager to the node at addr. Unlike
+// swarm.Manager.SwitchNode the cause of any error is kept so that host key
+// verification failures can be reported as such.
+func switchNode(swarmManager *swarm.Manager, addr string) error {
+	if err := swarmManager.Switcher().Switch(context.Background(), addr); err != nil {
+		return fmt.Errorf("error switching to node %s: %w", addr, err)
+	}
+
+	return nil
+}
+
+// unlockNode unlocks the node the swarm manager is switched to with
+// unlockKey if autolock has locked it after a restart. It does nothing if
+// the node is not locked.
+func unlockNode(swarmManager *swarm.Manager, unlockKey string) error {
+	if swarmManager.Runner() == nil {
+		return nil
+	}
+
+	node, err := swarmManager.GetInfo()
+	if err != nil {
+		return fmt.Errorf("error getting node info: %w", err)
+	}
+
+	if node.Swarm.LocalNodeState != localNodeStateLocked {
+		return nil
+	}
+
+	if unlockKey == "" {
+		return fmt.Errorf("error swarm manager %s is locked and no unlock_key is configured", swarmManager.Switcher().String())
+	}
+
+	// The unlock key is read from stdin so it never appears in a command line
+	if _, err := runCmdWithInput(swarmManager, unlockCommand, []byte(unlockKey+"\n")); err != nil {
+		return fmt.Errorf("error unlocking swarm manager %s: %w", swarmManager.Switcher().String(), err)
+	}
+
+	return nil
+}
+
+// getUnlockKey returns the current unlock key of an autolocked swarm
+func getUnlockKey(swarmManager *swarm.Manager, rotate bool) (string, error) {
+	cmd := unlockKeyCommand
+	if rotate {
+		cmd = rotateUnlockKeyCommand
+	}
+
+	stdout, err := runCmd(swarmManager, cmd)
+	if err != nil {
+		return "", fmt.Errorf("error running unlock key command: %w", err)
+	}
+
+	data, err := io.ReadAll(stdout)
+	if err != nil {
+		return "", fmt.Errorf("error reading stdout: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// shellQuote quotes s so that it is passed as a single argument to commands
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// isNotFound returns true if err was caused by a swarm object not existing
+func isNotFound(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "no such") || strings.Contains(msg, "not found")
+}
+
+// joinNode joins the given node to the swarm cluster managed by manager
+// using token. The swarm manager is left switched to the joined node.
+func joinNode(swarmManager *swarm.Manager, vm swarm.VMNode, manager swarm.VMNode, token string) error {
+	if err := switchNode(swarmManager, vm.PublicAddress); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf(
+		joinCommand,
+		vm.PrivateAddress,
+		vm.PrivateAddress,
+		token,
+		manager.PrivateAddress,
+	)
+	if _, err := runCmd(swarmManager, cmd); err != nil {
+		return fmt.Errorf("error running join command: %w", err)
+	}
+
+	return nil
+}
+
+// inspectNodes returns the full details of the given swarm nodes by id or
+// hostname. The swarm manager must be switched to a manager node.
+func inspectNodes(swarmManager *swarm.Manager, ids ...string) ([]Node, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	cmd := fmt.Sprintf(inspectCommand, strings.Join(ids, " "))
+	stdout, err := runCmd(swarmManager, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error running inspect command: %w", err)
+	}
+
+	var nodes []Node
+
+	decoder := json.NewDecoder(stdout)
+	for decoder.More() {
+		var node Node
+		if err := decoder.Decode(&node); err != nil {
+			return nil, fmt.Errorf("error parsing json data: %s", err)
+		}
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+// getNodes returns the full details of every node in the swarm cluster
+// sorted by hostname.
+func getNodes(swarmManager *swarm.Manager) ([]Node, error) {
+	statuses, err := swarmManager.GetNodes()
+	if err != nil {
+		return nil, fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	ids := make([]string, len(statuses))
+	for i, status := range statuses {
+		ids[i] = status.ID
+	}
+
+	nodes, err := inspectNodes(swarmManager, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Description.Hostname < nodes[j].Description.Hostname
+	})
+
+	return nodes, nil
+}
+
+// getTasks returns the tasks scheduled on the given node
+func getTasks(swarmManager *swarm.Manager, nodeID string) (swarm.Tasks, error) {
+	stdout, err := runCmd(swarmManager, fmt.Sprintf(tasksCommand, nodeID))
+	if err != nil {
+		return nil, fmt.Errorf("error running tasks command: %w", err)
+	}
+
+	var tasks swarm.Tasks
+
+	decoder := json.NewDecoder(stdout)
+	for decoder.More() {
+		var task swarm.TaskStatus
+		if err := decoder.Decode(&task); err != nil {
+			return nil, fmt.Errorf("error parsing json data: %s", err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// terminalTaskStates are the states of tasks that will never run again.
+// `docker node ps` keeps listing them as the history of a node.
+var terminalTaskStates = []string{"shutdown", "complete", "failed", "rejected", "remove", "orphaned"}
+
+// drained returns true if none of the given tasks is still running or about
+// to run
+func drained(tasks swarm.Tasks) bool {
+	for _, task := range tasks {
+		state := strings.ToLower(task.CurrentState)
+
+		terminal := false
+		for _, terminalState := range terminalTaskStates {
+			if strings.HasPrefix(state, terminalState) {
+				terminal = true
+				break
+			}
+		}
+
+		if !terminal {
+			return false
+		}
+	}
+
+	return true
+}
+
+// drainNode sets the availability of the given node to drain and blocks
+// until all of its tasks have been shut down and rescheduled on other nodes
+// or the timeout expires. The swarm manager must be switched to a manager.
+func drainNode(swarmManager *swarm.Manager, nodeID string, timeout time.Duration) error {
+	if _, err := runCmd(swarmManager, fmt.Sprintf(drainCommand, nodeID)); err != nil {
+		return fmt.Errorf("error running drain command: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	startedAt := time.Now()
+
+	for {
+		select {
+		case <-ticker.C:
+			tasks, err := getTasks(swarmManager, nodeID)
+			if err != nil {
+				// Transient errors are retried until the timeout expires
+				continue
+			}
+
+			if drained(tasks) {
+				return nil
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("error timed out waiting for %s to drain after %s", nodeID, time.Since(startedAt))
+		}
+	}
+}
+
+// updateNode updates the availability, role and labels of the given node
+// from current to desired. Empty values and nil labels are left unchanged,
+// otherwise labels not in desired are removed. The swarm manager must be
+// switched to a manager.
+func updateNode(swarmManager *swarm.Manager, nodeID string, current, desired NodeSpec) error {
+	var flags []string
+
+	if desired.Availability != "" && desired.Availability != current.Availability {
+		flags = append(flags, "--availability", desired.Availability)
+	}
+
+	if desired.Role != "" && desired.Role != current.Role {
+		flags = append(flags, "--role", desired.Role)
+	}
+
+	if desired.Labels != nil {
+		keys := make([]string, 0, len(current.Labels)+len(desired.Labels))
+		for key := range current.Labels {
+			keys = append(keys, key)
+		}
+		for key := range desired.Labels {
+			if _, ok := current.Labels[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			value, wanted := desired.Labels[key]
+			old, exists := current.Labels[key]
+			switch {
+			case !wanted:
+				flags = append(flags, "--label-rm", shellQuote(key))
+			case !exists || old != value:
+				flags = append(flags, "--label-add", shellQuote(fmt.Sprintf("%s=%s", key, value)))
+			}
+		}
+	}
+
+	if len(flags) == 0 {
+		return nil
+	}
+
+	if _, err := runCmd(swarmManager, fmt.Sprintf(nodeUpdateCommand, strings.Join(flags, " "), nodeID)); err != nil {
+		return fmt.Errorf("error running node update command: %w", err)
+	}
+
+	return nil
+}
+
+// inspectCluster returns the full details of the swarm cluster the swarm
+// manager is switched to
+func inspectCluster(swarmManager *swarm.Manager) (Cluster, error) {
+	stdout, err := runCmd(swarmManager, clusterInspectCommand)
+	if err != nil {
+		return Cluster{}, fmt.Errorf("error running cluster inspect command: %w", err)
+	}
+
+	var cluster Cluster
+
+	if err := json.NewDecoder(stdout).Decode(&cluster); err != nil {
+		return Cluster{}, fmt.Errorf("error parsing json data: %s", err)
+	}
+
+	return cluster, nil
+}
+
+// waitForCARotation blocks until the root CA rotation of the swarm cluster
+// has completed and every node that is not down trusts the new root CA or
+// the timeout expires. The hostnames of the nodes that have not rotated are
+// returned along with the error on timeout and the hostnames of the down
+// nodes, which rotate once they rejoin, are always returned. The swarm
+// manager must be switched to a manager.
+func waitForCARotation(swarmManager *swarm.Manager, timeout time.Duration) ([]string, []string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(caRotationPollInterval)
+	defer ticker.Stop()
+
+	startedAt := time.Now()
+
+	var pending, down []string
+
+	for {
+		select {
+		case <-ticker.C:
+			cluster, err := inspectCluster(swarmManager)
+			if err != nil {
+				// Transient errors are retried until the timeout expires
+				continue
+			}
+
+			nodes, err := getNodes(swarmManager)
+			if err != nil {
+				continue
+			}
+
+			pending, down = pendingRotation(nodes, cluster.TLSInfo.TrustRoot)
+
+			if !cluster.RootRotationInProgress && len(pending) == 0 {
+				return nil, down, nil
+			}
+		case <-ctx.Done():
+			return pending, down, fmt.Errorf("error timed out waiting for root CA rotation after %s", time.Since(startedAt))
+		}
+	}
+}
+
+// pendingRotation returns the hostnames of the nodes that do not trust the
+// given root CA yet and separately those of the down nodes, which cannot
+// rotate until they rejoin the swarm.
+func pendingRotation(nodes []Node, trustRoot string) (pending []string, down []string) {
+	for _, node := range nodes {
+		switch {
+		case node.Status.State == nodeStateDown:
+			down = append(down, node.Description.Hostname)
+		case node.Description.TLSInfo.TrustRoot != trustRoot:
+			pending = append(pending, node.Description.Hostname)
+		}
+	}
+
+	return pending, down
+}
+
+// inspectService returns the full details of the given service by id or name
+func inspectService(swarmManager *swarm.Manager, id string) (Service, error) {
+	stdout, err := runCmd(swarmManager, fmt.Sprintf(serviceInspectCommand, shellQuote(id)))
+	if err != nil {
+		return Service{}, fmt.Errorf("error running inspect command: %w", err)
+	}
+
+	var service Service
+
+	if err := json.NewDecoder(stdout).Decode(&service); err != nil {
+		return Service{}, fmt.Errorf("error parsing json data: %s", err)
+	}
+
+	return service, nil
+}
+
+// getNetworkName returns the name of the given network id
+func getNetworkName(swarmManager *swarm.Manager, id string) (string, error) {
+	stdout, err := runCmd(swarmManager, fmt.Sprintf(networkNameCommand, shellQuote(id)))
+	if err != nil {
+		return "", fmt.Errorf("error running network inspect command: %w", err)
+	}
+
+	data, err := io.ReadAll(stdout)
+	if err != nil {
+		return "", fmt.Errorf("error reading stdout: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// inspectNetwork returns the full details of the given network by id or name
+func inspectNetwork(swarmManager *swarm.Manager, id string) (Network, error) {
+	stdout, err := runCmd(swarmManager, fmt.Sprintf(networkInspectCommand, shellQuote(id)))
+	if err != nil {
+		return Network{}, fmt.Errorf("error running network inspect command: %w", err)
+	}
+
+	var network Network
+
+	if err := json.NewDecoder(stdout).Decode(&network); err != nil {
+		return Network{}, fmt.Errorf("error parsing json data: %s", err)
+	}
+
+	return network, nil
+}
+
+// inspectObject returns the full details of the given secret or config
+func inspectObject(swarmManager *swarm.Manager, kind, id string) (Object, error) {
+	stdout, err := runCmd(swarmManager, fmt.Sprintf(objectInspectCommand, kind, shellQuote(id)))
+	if err != nil {
+		return Object{}, fmt.Errorf("error running %s inspect command: %w", kind, err)
+	}
+
+	var object Object
+
+	if err := json.NewDecoder(stdout).Decode(&object); err != nil {
+		return Object{}, fmt.Errorf("error parsing json data: %s", err)
+	}
+
+	return object, nil
+}
+
+// getStackServices returns the services of the given stack
+func getStackServices(swarmManager *swarm.Manager, name string) ([]StackService, error) {
+	stdout, err := runCmd(swarmManager, fmt.Sprintf(stackServicesCommand, shellQuote(name)))
+	if err != nil {
+		return nil, fmt.Errorf("error running stack services command: %w", err)
+	}
+
+	var services []StackService
+
+	decoder := json.NewDecoder(stdout)
+	for decoder.More() {
+		var service StackService
+		if err := decoder.Decode(&service); err != nil {
+			return nil, fmt.Errorf("error parsing json data: %s", err)
+		}
+		services = append(services, service)
+	}
+
+	return services, nil
+}
@@ -0,0 +1,255 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aucloud/go-runcmd"
+	"github.com/aucloud/go-swarm"
+)
+
+// testSwitcher is a swarm.Switcher backed by a fake swarm cluster. It
+// records every command run as "<address>: <command>" and answers the
+// commands used to inspect the cluster from nodes.
+type testSwitcher struct {
+	current     string
+	nodes       []Node
+	unreachable map[string]bool
+	commands    []string
+}
+
+func newTestSwitcher(nodes ...Node) *testSwitcher {
+	return &testSwitcher{nodes: nodes, unreachable: make(map[string]bool)}
+}
+
+func (s *testSwitcher) String() string {
+	return s.current
+}
+
+func (s *testSwitcher) Switch(ctx context.Context, addr string) error {
+	if s.unreachable[addr] {
+		return fmt.Errorf("error dialing %s: connection refused", addr)
+	}
+	s.current = addr
+	return nil
+}
+
+func (s *testSwitcher) SwitchVia(ctx context.Context, addr string) error {
+	return s.Switch(ctx, addr)
+}
+
+func (s *testSwitcher) Runner() runcmd.Runner {
+	if s.current == "" {
+		return nil
+	}
+	return testRunner{s}
+}
+
+// ran returns the index of the first command run on addr starting with cmd
+// or -1 if there is none
+func (s *testSwitcher) ran(addr, cmd string) int {
+	for i, command := range s.commands {
+		if strings.HasPrefix(command, addr+": "+cmd) {
+			return i
+		}
+	}
+	return -1
+}
+
+// respond returns the output of cmd run on the node at addr
+func (s *testSwitcher) respond(addr, cmd string) string {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+
+	switch {
+	case strings.HasPrefix(cmd, "docker info"):
+		var info swarm.NodeInfo
+		for _, node := range s.nodes {
+			if node.Status.Addr == addr {
+				info.Swarm.NodeID = node.ID
+				info.Swarm.ControlAvailable = node.IsManager()
+			}
+		}
+		_ = encoder.Encode(info)
+	case strings.HasPrefix(cmd, "docker node ls"):
+		for _, node := range s.nodes {
+			_ = encoder.Encode(swarm.NodeStatus{ID: node.ID, Hostname: node.Description.Hostname})
+		}
+	case strings.HasPrefix(cmd, "docker node inspect"):
+		for _, node := range s.nodes {
+			if strings.Contains(cmd, " "+node.ID) {
+				_ = encoder.Encode(node)
+			}
+		}
+	}
+
+	return out.String()
+}
+
+type testRunner struct {
+	switcher *testSwitcher
+}
+
+func (r testRunner) Command(cmd string) (runcmd.CmdWorker, error) {
+	return &testCmd{switcher: r.switcher, addr: r.switcher.current, cmdline: cmd}, nil
+}
+
+type testCmd struct {
+	switcher *testSwitcher
+	addr     string
+	cmdline  string
+	stdin    bytes.Buffer
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+func (c *testCmd) Run() ([]string, error) {
+	var stdout bytes.Buffer
+	c.stdout = &stdout
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(stdout.String()), "\n"), c.Wait()
+}
+
+func (c *testCmd) Start() error {
+	c.switcher.commands = append(c.switcher.commands, c.addr+": "+c.cmdline)
+	if c.stdout != nil {
+		_, _ = io.WriteString(c.stdout, c.switcher.respond(c.addr, c.cmdline))
+	}
+	return nil
+}
+
+func (c *testCmd) Wait() error {
+	return nil
+}
+
+func (c *testCmd) StdinPipe() (io.WriteCloser, error) {
+	return nopWriteCloser{&c.stdin}, nil
+}
+
+func (c *testCmd) StdoutPipe() (io.Reader, error) {
+	return strings.NewReader(c.switcher.respond(c.addr, c.cmdline)), nil
+}
+
+func (c *testCmd) StderrPipe() (io.Reader, error) {
+	return strings.NewReader(""), nil
+}
+
+func (c *testCmd) SetStdout(buffer io.Writer) {
+	c.stdout = buffer
+}
+
+func (c *testCmd) SetStderr(buffer io.Writer) {
+	c.stderr = buffer
+}
+
+func (c *testCmd) GetCommandLine() string {
+	return c.cmdline
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// testNode returns a ready swarm node with the given role at addr
+func testNode(id, hostname, addr, role string) Node {
+	node := Node{ID: id, Spec: NodeSpec{Role: role, Availability: "active"}}
+	node.Description.Hostname = hostname
+	node.Status.State = "ready"
+	node.Status.Addr = addr
+	if role == swarm.ManagerRole {
+		node.ManagerStatus = &ManagerStatus{Addr: addr + ":2377", Reachability: "reachable"}
+	}
+	return node
+}
+
+func TestDrained(t *testing.T) {
+	testCases := []struct {
+		name     string
+		states   []string
+		expected bool
+	}{
+		{name: "no tasks", expected: true},
+		{name: "running", states: []string{"Shutdown 2 minutes ago", "Running 5 seconds ago"}, expected: false},
+		{name: "preparing", states: []string{"Preparing 1 second ago"}, expected: false},
+		{
+			name:     "history",
+			states:   []string{"Shutdown 2 minutes ago", "Failed 3 hours ago", "Complete 1 day ago", "Rejected 2 days ago"},
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var tasks swarm.Tasks
+			for _, state := range tc.states {
+				tasks = append(tasks, swarm.TaskStatus{CurrentState: state})
+			}
+			if actual := drained(tasks); actual != tc.expected {
+				t.Errorf("expected %t got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestValidateDuration(t *testing.T) {
+	if _, errs := validateDuration("10m", "drain_timeout"); len(errs) != 0 {
+		t.Errorf("expected 10m to be valid got %v", errs)
+	}
+	if _, errs := validateDuration("10 minutes", "drain_timeout"); len(errs) == 0 {
+		t.Error("expected 10 minutes to be invalid")
+	}
+}
+
+func TestPendingRotation(t *testing.T) {
+	node := func(hostname, state, trustRoot string) Node {
+		n := Node{Description: NodeDescription{Hostname: hostname, TLSInfo: TLSInfo{TrustRoot: trustRoot}}}
+		n.Status.State = state
+		return n
+	}
+
+	nodes := []Node{
+		node("manager1", "ready", "new"),
+		node("worker1", "ready", "old"),
+		node("worker2", "down", "old"),
+		node("worker3", "down", "new"),
+	}
+
+	pending, down := pendingRotation(nodes, "new")
+	if expected := []string{"worker1"}; !reflect.DeepEqual(pending, expected) {
+		t.Errorf("expected pending nodes %q got %q", expected, pending)
+	}
+	if expected := []string{"worker2", "worker3"}; !reflect.DeepEqual(down, expected) {
+		t.Errorf("expected down nodes %q got %q", expected, down)
+	}
+}
@@ -49,7 +49,7 @@ func dataSourceClusterRead(ctx context.Context, d *schema.ResourceData, m interf
 		})
 	}
 
-	if err := d.Set("created_at", node.Swarm.Cluster.CreatedAt); err != nil {
+	if err := d.Set("created_at", formatTimestamp(node.Swarm.Cluster.CreatedAt)); err != nil {
 		return diag.FromErr(err)
 	}
 	if err := d.Set("nodes", node.Swarm.Nodes); err != nil {
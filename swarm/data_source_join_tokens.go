@@ -0,0 +1,102 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/aucloud/go-swarm"
+)
+
+func dataSourceJoinTokensRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	node, err := swarmManager.GetInfo()
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error getting node info: %w", err))
+	}
+
+	workerToken, err := swarmManager.JoinToken(swarm.WorkerRole)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error getting worker join token: %w", err))
+	}
+
+	managerToken, err := swarmManager.JoinToken(swarm.ManagerRole)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error getting manager join token: %w", err))
+	}
+
+	var managerAddrs []string
+	for _, remoteManager := range node.Swarm.RemoteManagers {
+		managerAddrs = append(managerAddrs, remoteManager.Addr)
+	}
+	sort.Strings(managerAddrs)
+
+	if err := d.Set("worker_token", workerToken); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("manager_token", managerToken); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("manager_addrs", managerAddrs); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(node.Swarm.Cluster.ID)
+
+	return diags
+}
+
+func dataSourceJoinTokens() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceJoinTokensRead,
+		Schema: map[string]*schema.Schema{
+			"worker_token": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"manager_token": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"manager_addrs": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
@@ -22,40 +22,143 @@ package swarm
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"github.com/aucloud/go-swarm"
 )
 
+// nodeFilter restricts the nodes returned by the swarm_nodes data source
+type nodeFilter struct {
+	role         string
+	availability string
+	labels       map[string]interface{}
+}
+
+// expandNodeFilter returns the filter configured in the given filter block
+func expandNodeFilter(filters []interface{}) nodeFilter {
+	if len(filters) == 0 || filters[0] == nil {
+		return nodeFilter{}
+	}
+
+	filter := filters[0].(map[string]interface{})
+
+	return nodeFilter{
+		role:         filter["role"].(string),
+		availability: filter["availability"].(string),
+		labels:       filter["labels"].(map[string]interface{}),
+	}
+}
+
+// matches returns true if the node matches all of the filter's criteria.
+// Labels match either the node's labels or its engine labels.
+func (f nodeFilter) matches(node Node) bool {
+	if f.role != "" && node.Spec.Role != f.role {
+		return false
+	}
+
+	if f.availability != "" && node.Spec.Availability != f.availability {
+		return false
+	}
+
+	for key, value := range f.labels {
+		if label, ok := node.Spec.Labels[key]; ok && label == value {
+			continue
+		}
+		if label, ok := node.Description.Engine.Labels[key]; ok && label == value {
+			continue
+		}
+		return false
+	}
+
+	return true
+}
+
+// flattenLabels returns the labels as sorted key=value pairs as reported by
+// the deprecated labels attribute
+func flattenLabels(labels map[string]string) []string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// flattenNode returns the attributes of the given node. The operating
+// system and kernel are only reported by the engine of a node itself so are
+// only known for the node we are connected to.
+func flattenNode(node Node, info swarm.NodeInfo) map[string]interface{} {
+	attributes := map[string]interface{}{
+		"id":             node.ID,
+		"name":           node.Description.Hostname,
+		"hostname":       node.Description.Hostname,
+		"role":           node.Spec.Role,
+		"availability":   node.Spec.Availability,
+		"status":         node.Status.State,
+		"address":        node.Status.Addr,
+		"reachability":   "",
+		"leader":         false,
+		"engine_version": node.Description.Engine.EngineVersion,
+		"server_version": node.Description.Engine.EngineVersion,
+		"architecture":   node.Description.Platform.Architecture,
+		"os_type":        node.Description.Platform.OS,
+		"cpus":           int(node.Description.Resources.NanoCPUs / 1e9),
+		"nano_cpus":      int(node.Description.Resources.NanoCPUs),
+		"memory":         int(node.Description.Resources.MemoryBytes),
+		"labels":         flattenLabels(node.Description.Engine.Labels),
+		"engine_labels":  node.Description.Engine.Labels,
+		"node_labels":    node.Spec.Labels,
+		"manager":        node.IsManager(),
+	}
+
+	if node.ManagerStatus != nil {
+		attributes["reachability"] = node.ManagerStatus.Reachability
+		attributes["leader"] = node.ManagerStatus.Leader
+	}
+
+	if node.ID == info.Swarm.NodeID {
+		attributes["os"] = info.OperatingSystem
+		attributes["os_version"] = info.OSVersion
+		attributes["kernel_version"] = info.KernelVersion
+	}
+
+	return attributes
+}
+
 func dataSourceNodesRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	swarmManager := m.(*swarm.Manager)
 
 	// Warning or errors can be collected in a slice type
 	var diags diag.Diagnostics
 
-	nodes := make([]map[string]interface{}, 0)
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
 
-	node, err := swarmManager.GetInfo()
+	info, err := swarmManager.GetInfo()
 	if err != nil {
 		return diag.FromErr(fmt.Errorf("error getting node info: %w", err))
 	}
-	nodes = append(nodes, map[string]interface{}{
-		"id":             node.ID,
-		"name":           node.Name,
-		"labels":         node.Labels,
-		"cpus":           node.NCPU,
-		"memory":         node.MemTotal,
-		"os":             node.OperatingSystem,
-		"os_type":        node.OSType,
-		"os_version":     node.OSVersion,
-		"kernel_version": node.KernelVersion,
-		"server_version": node.ServerVersion,
-		"manager":        node.IsManager(),
-	})
+
+	members, err := getNodes(swarmManager)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error listing nodes: %w", err))
+	}
+
+	filter := expandNodeFilter(d.Get("filter").([]interface{}))
+
+	nodes := make([]map[string]interface{}, 0, len(members))
+	for _, node := range members {
+		if filter.matches(node) {
+			nodes = append(nodes, flattenNode(node, info))
+		}
+	}
 
 	if err := d.Set("all", nodes); err != nil {
 		return diag.FromErr(err)
@@ -71,6 +174,32 @@ func dataSourceNodes() *schema.Resource {
 	return &schema.Resource{
 		ReadContext: dataSourceNodesRead,
 		Schema: map[string]*schema.Schema{
+			"filter": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"role": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringInSlice([]string{swarm.ManagerRole, swarm.WorkerRole}, false),
+						},
+						"availability": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringInSlice([]string{"active", "pause", "drain"}, false),
+						},
+						"labels": {
+							Type:     schema.TypeMap,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
 			"all": {
 				Type:     schema.TypeList,
 				Computed: true,
@@ -84,26 +213,84 @@ func dataSourceNodes() *schema.Resource {
 							Type:     schema.TypeString,
 							Computed: true,
 						},
+						"hostname": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"role": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"availability": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"status": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"reachability": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"leader": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						// labels predates engine_labels and is kept so
+						// that existing configurations continue to work
 						"labels": {
-							Type:     schema.TypeList,
+							Type:       schema.TypeList,
+							Computed:   true,
+							Deprecated: "use engine_labels or node_labels instead",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"engine_labels": {
+							Type:     schema.TypeMap,
 							Computed: true,
 							Elem: &schema.Schema{
 								Type: schema.TypeString,
 							},
 						},
-						"os": {
+						"node_labels": {
+							Type:     schema.TypeMap,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"architecture": {
 							Type:     schema.TypeString,
 							Computed: true,
 						},
+						// os, os_version and kernel_version are only known
+						// for the node the provider is connected to
+						"os": {
+							Type:       schema.TypeString,
+							Computed:   true,
+							Deprecated: "only set for the node the provider is connected to, use os_type instead",
+						},
 						"os_type": {
 							Type:     schema.TypeString,
 							Computed: true,
 						},
 						"os_version": {
-							Type:     schema.TypeString,
-							Computed: true,
+							Type:       schema.TypeString,
+							Computed:   true,
+							Deprecated: "only set for the node the provider is connected to",
 						},
 						"kernel_version": {
+							Type:       schema.TypeString,
+							Computed:   true,
+							Deprecated: "only set for the node the provider is connected to",
+						},
+						"engine_version": {
 							Type:     schema.TypeString,
 							Computed: true,
 						},
@@ -115,6 +302,10 @@ func dataSourceNodes() *schema.Resource {
 							Type:     schema.TypeInt,
 							Computed: true,
 						},
+						"nano_cpus": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
 						"memory": {
 							Type:     schema.TypeInt,
 							Computed: true,
@@ -0,0 +1,208 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/aucloud/go-runcmd"
+
+	"github.com/aucloud/go-swarm"
+)
+
+const (
+	defaultDockerPort    = "2375"
+	defaultDockerTLSPort = "2376"
+
+	dockerVersionCommand = `docker version --format "{{ .Server.Version }}"`
+)
+
+// dockerHostConfig is how the provider connects to the Docker Engine API of
+// swarm nodes, like the DOCKER_HOST, DOCKER_CERT_PATH and DOCKER_TLS_VERIFY
+// environment variables of the docker CLI
+type dockerHostConfig struct {
+	host string
+
+	// tlsVerify verifies the engine's certificate against caCert
+	tlsVerify bool
+
+	caCert     string
+	clientCert string
+	clientKey  string
+}
+
+// tls returns true if the engine API is served over TLS
+func (c dockerHostConfig) tls() bool {
+	return c.tlsVerify || c.caCert != "" || c.clientCert != "" || c.clientKey != ""
+}
+
+// dockerHostSwitcher is a swarm.Switcher that reaches the Docker Engine API
+// of swarm nodes over tcp, optionally with mutual TLS, or a unix socket. It
+// does not speak the API itself but runs the local docker CLI against the
+// current node, so the docker CLI must be installed where Terraform runs.
+type dockerHostSwitcher struct {
+	sync.RWMutex
+
+	config dockerHostConfig
+
+	// scheme and port of config.host used to reach the other nodes
+	scheme string
+	port   string
+
+	host   string
+	runner runcmd.Runner
+}
+
+var _ swarm.Switcher = (*dockerHostSwitcher)(nil)
+
+// newDockerHostSwitcher constructs a new Switcher that runs the local docker
+// CLI against the Docker Engine API of nodes with the given configuration
+func newDockerHostSwitcher(config dockerHostConfig) (*dockerHostSwitcher, error) {
+	u, err := url.Parse(config.host)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing docker host %s: %w", config.host, err)
+	}
+
+	s := &dockerHostSwitcher{config: config, scheme: u.Scheme}
+
+	switch u.Scheme {
+	case "tcp":
+		s.port = u.Port()
+		if s.port == "" {
+			s.port = defaultDockerPort
+			if config.tls() {
+				s.port = defaultDockerTLSPort
+			}
+		}
+	case "unix":
+	default:
+		return nil, fmt.Errorf("error unsupported docker host %s, expected tcp:// or unix://", config.host)
+	}
+
+	return s, nil
+}
+
+func (s *dockerHostSwitcher) String() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.host
+}
+
+func (s *dockerHostSwitcher) Runner() runcmd.Runner {
+	s.RLock()
+	defer s.RUnlock()
+	return s.runner
+}
+
+// Switch connects to the engine of the node at nodeAddr on the port of the
+// docker host, or the docker host itself if nodeAddr is empty. A unix socket
+// only reaches the local engine so nodeAddr is ignored like with use_local.
+func (s *dockerHostSwitcher) Switch(ctx context.Context, nodeAddr string) error {
+	host := s.config.host
+	if nodeAddr != "" && s.scheme == "tcp" {
+		addr, _, err := net.SplitHostPort(nodeAddr)
+		if err != nil {
+			addr = nodeAddr
+		}
+		host = fmt.Sprintf("tcp://%s", net.JoinHostPort(addr, s.port))
+	}
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("error finding the docker CLI, it is required to use docker_host: %w", err)
+	}
+
+	local, err := runcmd.NewLocalRunner()
+	if err != nil {
+		return fmt.Errorf("error creating local runner: %w", err)
+	}
+
+	runner := &dockerHostRunner{runner: local, flags: s.flags(host)}
+
+	worker, err := runner.Command(dockerVersionCommand)
+	if err != nil {
+		return fmt.Errorf("error creating worker: %w", err)
+	}
+
+	if _, err := worker.Run(); err != nil {
+		return fmt.Errorf("error connecting to %s: %w", host, err)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.host = host
+	s.runner = runner
+
+	return nil
+}
+
+// SwitchVia connects to the node at nodeAddr like Switch. Engine APIs are
+// reached directly so there is nothing to tunnel through.
+func (s *dockerHostSwitcher) SwitchVia(ctx context.Context, nodeAddr string) error {
+	return s.Switch(ctx, nodeAddr)
+}
+
+// flags returns the global docker CLI flags to talk to the engine at host
+func (s *dockerHostSwitcher) flags(host string) string {
+	flags := []string{"--host", shellQuote(host)}
+
+	if s.config.tlsVerify {
+		flags = append(flags, "--tlsverify")
+	} else if s.config.tls() {
+		flags = append(flags, "--tls")
+	}
+
+	if s.config.caCert != "" {
+		flags = append(flags, "--tlscacert", shellQuote(os.ExpandEnv(s.config.caCert)))
+	}
+	if s.config.clientCert != "" {
+		flags = append(flags, "--tlscert", shellQuote(os.ExpandEnv(s.config.clientCert)))
+	}
+	if s.config.clientKey != "" {
+		flags = append(flags, "--tlskey", shellQuote(os.ExpandEnv(s.config.clientKey)))
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// dockerHostRunner is a runcmd.Runner that runs commands locally, pointing
+// docker commands at the engine of the current node. Commands must start
+// with `docker ` to be pointed at the engine, so they are never prefixed
+// with `env` or the like.
+type dockerHostRunner struct {
+	runner runcmd.Runner
+	flags  string
+}
+
+func (r *dockerHostRunner) Command(cmdline string) (runcmd.CmdWorker, error) {
+	if rest := strings.TrimPrefix(cmdline, "docker "); rest != cmdline {
+		cmdline = fmt.Sprintf("docker %s %s", r.flags, rest)
+	}
+
+	return r.runner.Command(cmdline)
+}
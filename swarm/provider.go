@@ -22,10 +22,14 @@ package swarm
 import (
 	"context"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"github.com/aucloud/go-swarm"
 )
@@ -51,6 +55,19 @@ func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}
 		switcher swarm.Switcher
 	)
 
+	dockerHost := d.Get("docker_host").(string)
+	bastionHost := d.Get("bastion_host").(string)
+
+	if dockerHost != "" && (sshAddr != "" || bastionHost != "") {
+		return nil, diag.FromErr(fmt.Errorf("error docker_host cannot be combined with ssh_addr or bastion_host"))
+	}
+
+	// DOCKER_HOST is only honoured if nothing points at ssh so that an
+	// exported DOCKER_HOST does not silently replace the ssh transport
+	if dockerHost == "" && sshAddr == "" && sshUser == "" && sshKey == "" && bastionHost == "" && d.Get("ssh_key_file").(string) == "" {
+		dockerHost = os.Getenv("DOCKER_HOST")
+	}
+
 	if useLocal {
 		switcher, err = swarm.NewLocalSwitcher()
 		if err != nil {
@@ -69,12 +86,81 @@ func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}
 			})
 			return nil, diags
 		}
+	} else if dockerHost != "" {
+		config := dockerHostConfig{
+			host:       dockerHost,
+			caCert:     d.Get("ca_cert").(string),
+			clientCert: d.Get("client_cert").(string),
+			clientKey:  d.Get("client_key").(string),
+		}
+
+		// Like the docker CLI any value of DOCKER_TLS_VERIFY enables it
+		config.tlsVerify = os.Getenv("DOCKER_TLS_VERIFY") != "" || config.caCert != ""
+
+		switcher, err = newDockerHostSwitcher(config)
+		if err != nil {
+			return nil, diag.FromErr(fmt.Errorf("error creating docker host switcher: %w", err))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err = switcher.Switch(ctx, ""); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Unable to switch nodes",
+				Detail: fmt.Sprintf(
+					"Unable to connect to the Docker Engine at %s with the local docker CLI: %s",
+					dockerHost, err.Error(),
+				),
+			})
+			return nil, diags
+		}
 	} else {
-		switcher, err = swarm.NewSSHSwitcher(sshUser, sshAddr, sshKey, timeout)
+		config := sshConfig{
+			user: sshUser,
+			auth: sshAuth{
+				key:        sshKey,
+				keyFile:    d.Get("ssh_key_file").(string),
+				passphrase: d.Get("ssh_key_passphrase").(string),
+				agent:      d.Get("ssh_agent").(bool),
+			},
+			timeout:        timeout,
+			hostKeyPolicy:  d.Get("host_key_policy").(string),
+			knownHostsFile: d.Get("known_hosts_file").(string),
+			hostKeys:       make(map[string]string),
+		}
+
+		for addr, hostKey := range d.Get("host_keys").(map[string]interface{}) {
+			config.hostKeys[addr] = hostKey.(string)
+		}
+
+		// The port of ssh_addr is used to connect to every node
+		if _, port, err := net.SplitHostPort(sshAddr); err == nil {
+			config.port = port
+		}
+
+		if bastionHost != "" {
+			config.bastion = &bastionConfig{
+				host: bastionHost,
+				port: d.Get("bastion_port").(string),
+				user: d.Get("bastion_user").(string),
+				key:  d.Get("bastion_key").(string),
+			}
+		}
+
+		switcher, err = newSSHSwitcher(config)
 		if err != nil {
 			return nil, diag.FromErr(fmt.Errorf("error creating ssh switcher: %w", err))
 		}
 
+		if config.hostKeyPolicy == hostKeyPolicyInsecure {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Host keys are not verified",
+				Detail:   "The host_key_policy is insecure so host keys of nodes without a pinned host key are not verified and connections may be intercepted. Use accept-new or strict instead.",
+			})
+		}
+
 		if sshAddr != "" {
 			ctx, cancel := context.WithTimeout(context.Background(), timeout)
 			defer cancel()
@@ -131,14 +217,111 @@ func Provider() *schema.Provider {
 				Sensitive:   true,
 				DefaultFunc: schema.EnvDefaultFunc("SSH_KEY", nil),
 			},
+			"ssh_key_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("SSH_KEY_FILE", nil),
+			},
+			"ssh_key_passphrase": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("SSH_KEY_PASSPHRASE", nil),
+			},
+			"ssh_agent": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("SSH_AGENT", false),
+			},
+			"host_key_policy": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("SSH_HOST_KEY_POLICY", hostKeyPolicyAcceptNew),
+				ValidateFunc: validation.StringInSlice([]string{hostKeyPolicyStrict, hostKeyPolicyAcceptNew, hostKeyPolicyInsecure}, false),
+			},
+			"host_keys": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"known_hosts_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("SSH_KNOWN_HOSTS", nil),
+			},
+			"bastion_host": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("BASTION_HOST", nil),
+			},
+			"bastion_port": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("BASTION_PORT", "22"),
+			},
+			"bastion_user": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("BASTION_USER", nil),
+			},
+			"bastion_key": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("BASTION_KEY", nil),
+			},
+			"docker_host": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"ssh_addr", "bastion_host"},
+			},
+			"ca_cert": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: dockerCertPathDefaultFunc("ca.pem"),
+			},
+			"client_cert": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: dockerCertPathDefaultFunc("cert.pem"),
+			},
+			"client_key": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: dockerCertPathDefaultFunc("key.pem"),
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"swarm_cluster": resourceCluster(),
+			"swarm_cluster":             resourceCluster(),
+			"swarm_service":             resourceService(),
+			"swarm_network":             resourceNetwork(),
+			"swarm_secret":              resourceSecret(),
+			"swarm_config":              resourceConfig(),
+			"swarm_stack":               resourceStack(),
+			"swarm_node":                resourceNode(),
+			"swarm_join_token_rotation": resourceJoinTokenRotation(),
+			"swarm_cluster_spec":        resourceClusterSpec(),
+			"swarm_ca":                  resourceCA(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
-			"swarm_cluster": dataSourceCluster(),
-			"swarm_nodes":   dataSourceNodes(),
+			"swarm_cluster":     dataSourceCluster(),
+			"swarm_nodes":       dataSourceNodes(),
+			"swarm_join_tokens": dataSourceJoinTokens(),
 		},
 		ConfigureContextFunc: providerConfigure,
 	}
 }
+
+// dockerCertPathDefaultFunc defaults to the named file in DOCKER_CERT_PATH.
+// Like the docker CLI it only applies if DOCKER_TLS_VERIFY is set.
+func dockerCertPathDefaultFunc(name string) schema.SchemaDefaultFunc {
+	return func() (interface{}, error) {
+		if os.Getenv("DOCKER_TLS_VERIFY") == "" {
+			return nil, nil
+		}
+		if certPath := os.Getenv("DOCKER_CERT_PATH"); certPath != "" {
+			return filepath.Join(certPath, name), nil
+		}
+		return nil, nil
+	}
+}
@@ -0,0 +1,229 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/aucloud/go-swarm"
+)
+
+// resourceCA rotates the root CA of the swarm cluster whenever it is
+// created, which is every time its triggers or CA change.
+func resourceCA() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceCACreate,
+		ReadContext:   resourceCARead,
+		UpdateContext: resourceCAUpdate,
+		DeleteContext: resourceCADelete,
+		Schema: map[string]*schema.Schema{
+			"triggers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"ca_cert": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				RequiredWith: []string{"ca_key"},
+			},
+			"ca_key": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Sensitive:    true,
+				RequiredWith: []string{"ca_cert"},
+			},
+			"rotation_timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "10m",
+				ValidateFunc: validateDuration,
+			},
+			"trust_root": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"rotated_at": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// rotateCA starts the rotation of the root CA of the swarm cluster. An
+// external CA certificate and key are copied to a temporary directory on
+// the manager as `docker swarm ca` only reads them from files.
+func rotateCA(swarmManager *swarm.Manager, cert, key string) error {
+	if cert == "" {
+		if _, err := runCmd(swarmManager, fmt.Sprintf(caRotateCommand, "")); err != nil {
+			return fmt.Errorf("error running ca rotate command: %w", err)
+		}
+		return nil
+	}
+
+	stdout, err := runCmd(swarmManager, tempDirCommand)
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+
+	data, err := io.ReadAll(stdout)
+	if err != nil {
+		return fmt.Errorf("error reading stdout: %w", err)
+	}
+
+	dir := strings.TrimSpace(string(data))
+	// The key is removed again once the rotation has started
+	defer runCmd(swarmManager, fmt.Sprintf(removeDirCommand, shellQuote(dir)))
+
+	certFile, keyFile := path.Join(dir, "ca.pem"), path.Join(dir, "ca-key.pem")
+
+	if _, err := runCmdWithInput(swarmManager, fmt.Sprintf(writeFileCommand, shellQuote(certFile)), []byte(cert)); err != nil {
+		return fmt.Errorf("error writing ca certificate: %w", err)
+	}
+	if _, err := runCmdWithInput(swarmManager, fmt.Sprintf(writeFileCommand, shellQuote(keyFile)), []byte(key)); err != nil {
+		return fmt.Errorf("error writing ca key: %w", err)
+	}
+
+	flags := fmt.Sprintf("--ca-cert %s --ca-key %s", shellQuote(certFile), shellQuote(keyFile))
+	if _, err := runCmd(swarmManager, fmt.Sprintf(caRotateCommand, flags)); err != nil {
+		return fmt.Errorf("error running ca rotate command: %w", err)
+	}
+
+	return nil
+}
+
+func resourceCACreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	rotationTimeout := d.Get("rotation_timeout").(string)
+	timeout, err := time.ParseDuration(rotationTimeout)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error parsing rotation timeout %s: %w", rotationTimeout, err))
+	}
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	cluster, err := inspectCluster(swarmManager)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error inspecting swarm cluster: %w", err))
+	}
+
+	if err := rotateCA(swarmManager, d.Get("ca_cert").(string), d.Get("ca_key").(string)); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to rotate swarm root CA",
+			Detail:   fmt.Sprintf("Unable to rotate root CA of swarm cluster %s: %s", cluster.ID, err),
+		})
+		return diags
+	}
+
+	d.SetId(cluster.ID)
+
+	if err := d.Set("rotated_at", time.Now().Format(time.RFC3339)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	pending, down, err := waitForCARotation(swarmManager, timeout)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Timed out waiting for swarm root CA rotation",
+			Detail: fmt.Sprintf(
+				"The root CA rotation of swarm cluster %s did not complete: %s. Nodes that have not rotated yet: %s. Nodes that are down: %s",
+				cluster.ID, err, strings.Join(pending, ", "), strings.Join(down, ", "),
+			),
+		})
+		return diags
+	}
+	if len(down) > 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Swarm nodes are down during root CA rotation",
+			Detail: fmt.Sprintf(
+				"The nodes %s of swarm cluster %s are down and will only trust the new root CA once they rejoin",
+				strings.Join(down, ", "), cluster.ID,
+			),
+		})
+	}
+
+	return append(diags, resourceCARead(ctx, d, m)...)
+}
+
+func resourceCARead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	cluster, err := inspectCluster(swarmManager)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error inspecting swarm cluster: %w", err))
+	}
+
+	// The root CA belongs to a swarm cluster that no longer exists
+	if cluster.ID != d.Id() {
+		d.SetId("")
+		return diags
+	}
+
+	if err := d.Set("trust_root", cluster.TLSInfo.TrustRoot); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+func resourceCAUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Only rotation_timeout can change without rotating the root CA again
+	return resourceCARead(ctx, d, m)
+}
+
+func resourceCADelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	// The root CA cannot be un-rotated, removing the resource only forgets it
+	d.SetId("")
+
+	return diags
+}
@@ -21,11 +21,19 @@ package swarm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"github.com/aucloud/go-swarm"
 )
@@ -36,61 +44,126 @@ func resourceCluster() *schema.Resource {
 		ReadContext:   resourceClusterRead,
 		UpdateContext: resourceClusterUpdate,
 		DeleteContext: resourceClusterDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceClusterImport,
+		},
+		CustomizeDiff: resourceClusterCustomizeDiff,
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Type:    resourceClusterV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceClusterStateUpgradeV0,
+				Version: 0,
+			},
+		},
 		Schema: map[string]*schema.Schema{
 			"skip_manager_validation": {
 				Type:     schema.TypeBool,
 				Optional: true,
 				Default:  false,
 			},
+			"drain_before_remove": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+			},
+			"drain_timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "10m",
+				ValidateFunc: validateDuration,
+			},
+			"unlock_key": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
 			"nodes": {
-				Type:     schema.TypeList,
+				Type:     schema.TypeSet,
 				Required: true,
-				Elem: &schema.Resource{
-					Schema: map[string]*schema.Schema{
-						"hostname": {
-							Type:     schema.TypeString,
-							Required: true,
-						},
-						"public_address": {
-							Type:     schema.TypeString,
-							Required: true,
-						},
-						"private_address": {
-							Type:     schema.TypeString,
-							Required: true,
-						},
-						"tags": {
-							Type:     schema.TypeMap,
-							Required: true,
-							Elem:     &schema.Schema{Type: schema.TypeString},
-						},
-					},
-				},
+				Elem:     resourceClusterNode(),
 			},
 			"created_at": {
 				Type:     schema.TypeString,
-				Optional: true,
 				Computed: true,
 			},
 
 			"updated_at": {
 				Type:     schema.TypeString,
-				Optional: true,
 				Computed: true,
 			},
 		},
 	}
 }
 
-func resourceClusterCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	// Warning or errors can be collected in a slice type
-	var diags diag.Diagnostics
-
-	swarmManager := m.(*swarm.Manager)
-
-	force := d.Get("skip_manager_validation").(bool)
+// resourceClusterNode is the schema of an element of the `nodes` block
+func resourceClusterNode() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"hostname": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"public_address": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"private_address": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"tags": {
+				Type:     schema.TypeMap,
+				Required: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"host_key": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"ssh_user": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"ssh_port": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			"ssh_key": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"ssh_address": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"labels": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"node_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"status": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"reachability": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
 
-	nodes := d.Get("nodes").([]interface{})
+// expandVMNodes converts the `nodes` block of a swarm_cluster into the
+// swarm.VMNodes understood by the swarm manager. The `labels` of a node are
+// merged into its `labels` tag, taking precedence over the tag.
+func expandVMNodes(nodes []interface{}) swarm.VMNodes {
 	vmnodes := make(swarm.VMNodes, len(nodes))
 
 	for i, node := range nodes {
@@ -100,6 +173,14 @@ func resourceClusterCreate(ctx context.Context, d *schema.ResourceData, m interf
 			tags[k] = v.(string)
 		}
 
+		if labels, ok := node["labels"].(map[string]interface{}); ok && len(labels) > 0 {
+			merged := decodeLabels(tags[swarm.LabelsTag])
+			for k, v := range labels {
+				merged[k] = v.(string)
+			}
+			tags[swarm.LabelsTag] = encodeLabels(merged)
+		}
+
 		vmnodes[i] = swarm.VMNode{
 			Hostname:       node["hostname"].(string),
 			PublicAddress:  node["public_address"].(string),
@@ -108,6 +189,329 @@ func resourceClusterCreate(ctx context.Context, d *schema.ResourceData, m interf
 		}
 	}
 
+	return vmnodes
+}
+
+// hostnamePattern matches DNS hostnames such as node1.example.com
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*\.?$`)
+
+// plannedNode is the part of an element of the `nodes` block validated at
+// plan time. Values that are not known yet are empty.
+type plannedNode struct {
+	hostname       string
+	publicAddress  string
+	privateAddress string
+	role           string
+	roleKnown      bool
+}
+
+// resourceClusterCustomizeDiff validates the topology of the `nodes` block
+// at plan time so that invalid cluster configurations are caught before any
+// changes are made to the nodes.
+func resourceClusterCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	config := d.GetRawConfig()
+	if config.IsNull() || !config.IsKnown() {
+		return nil
+	}
+
+	nodes, ok := plannedNodes(config.GetAttr("nodes"))
+	if !ok {
+		return nil
+	}
+
+	// The number of managers is only validated once it is known whether it
+	// may be skipped
+	skipManagerValidation := !d.NewValueKnown("skip_manager_validation") || d.Get("skip_manager_validation").(bool)
+
+	return validateNodes(nodes, skipManagerValidation)
+}
+
+// plannedNodes returns the elements of the `nodes` block of the raw
+// configuration. Unknown values, including whole elements, are returned
+// empty. ok is false if the block itself is not known yet.
+func plannedNodes(nodes cty.Value) ([]plannedNode, bool) {
+	if nodes.IsNull() || !nodes.IsKnown() {
+		return nil, false
+	}
+
+	knownString := func(v cty.Value) string {
+		if v.IsNull() || !v.IsKnown() {
+			return ""
+		}
+		return v.AsString()
+	}
+
+	var planned []plannedNode
+	for it := nodes.ElementIterator(); it.Next(); {
+		_, node := it.Element()
+		if node.IsNull() || !node.IsKnown() {
+			planned = append(planned, plannedNode{})
+			continue
+		}
+
+		p := plannedNode{
+			hostname:       knownString(node.GetAttr("hostname")),
+			publicAddress:  knownString(node.GetAttr("public_address")),
+			privateAddress: knownString(node.GetAttr("private_address")),
+		}
+
+		tags := node.GetAttr("tags")
+		if !tags.IsNull() && tags.IsKnown() {
+			role, ok := tags.AsValueMap()[swarm.RoleTag]
+			p.roleKnown = !ok || role.IsKnown()
+			if ok && p.roleKnown {
+				p.role = knownString(role)
+			}
+		}
+
+		planned = append(planned, p)
+	}
+
+	return planned, true
+}
+
+// validateNodes validates the hostnames, addresses and roles of the planned
+// nodes. Values that are not known yet are skipped and the managers are only
+// counted once every role is known.
+func validateNodes(nodes []plannedNode, skipManagerValidation bool) error {
+	var managers int
+
+	rolesKnown := true
+
+	hostnames := make(map[string]bool)
+	addresses := make(map[string]string)
+
+	for _, node := range nodes {
+		if node.hostname != "" {
+			if hostnames[node.hostname] {
+				return fmt.Errorf("error duplicate hostname %q in nodes", node.hostname)
+			}
+			hostnames[node.hostname] = true
+		}
+
+		for _, address := range []struct{ key, value string }{
+			{"public_address", node.publicAddress},
+			{"private_address", node.privateAddress},
+		} {
+			if address.value == "" {
+				continue
+			}
+
+			if !validAddress(address.value) {
+				return fmt.Errorf(
+					"error invalid %s %q for node %q, expected an IP address or hostname",
+					address.key, address.value, node.hostname,
+				)
+			}
+
+			// The same node may use the same address as both its public
+			// and private address but no two nodes may share an address.
+			if other, ok := addresses[address.value]; ok && other != node.hostname {
+				return fmt.Errorf("error duplicate address %q in nodes", address.value)
+			}
+			addresses[address.value] = node.hostname
+		}
+
+		if !node.roleKnown {
+			rolesKnown = false
+			continue
+		}
+
+		switch node.role {
+		case swarm.ManagerRole:
+			managers++
+		case swarm.WorkerRole:
+		default:
+			return fmt.Errorf(
+				"error invalid %s tag %q for node %q, expected %q or %q",
+				swarm.RoleTag, node.role, node.hostname, swarm.ManagerRole, swarm.WorkerRole,
+			)
+		}
+	}
+
+	// Unable to count managers until all roles are known
+	if !rolesKnown {
+		return nil
+	}
+
+	if managers == 0 {
+		return fmt.Errorf("error no managers found, at least one node must have the %s tag %q", swarm.RoleTag, swarm.ManagerRole)
+	}
+
+	// An even number of managers tolerates no more failures than one less
+	if !skipManagerValidation && managers%2 == 0 {
+		return fmt.Errorf(
+			"error expected an odd number of managers but got %d, an even number of managers does not improve fault tolerance (set skip_manager_validation to override)",
+			managers,
+		)
+	}
+
+	return nil
+}
+
+// validAddress returns true if the address is an IP address or a hostname
+func validAddress(address string) bool {
+	if net.ParseIP(address) != nil {
+		return true
+	}
+
+	return len(address) <= 253 && hostnamePattern.MatchString(address)
+}
+
+// encodeLabels encodes swarm node labels in the format of the `labels` tag
+func encodeLabels(labels map[string]string) string {
+	values := url.Values{}
+	for k, v := range labels {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// decodeLabels decodes the `labels` tag into swarm node labels. Labels
+// that cannot be parsed are ignored.
+func decodeLabels(tag string) map[string]string {
+	labels := make(map[string]string)
+
+	values, err := swarm.ParseLabels(tag)
+	if err != nil {
+		return labels
+	}
+
+	for k, v := range values {
+		labels[k] = strings.Join(v, ",")
+	}
+
+	return labels
+}
+
+// labelsEqual returns true if the `labels` tag describes the given swarm
+// node labels regardless of ordering and encoding.
+func labelsEqual(tag string, labels map[string]string) bool {
+	values, err := swarm.ParseLabels(tag)
+	if err != nil || len(values) != len(labels) {
+		return false
+	}
+	for k, v := range labels {
+		if strings.Join(values[k], ",") != v {
+			return false
+		}
+	}
+	return true
+}
+
+// flattenNodes converts the given swarm nodes into the `nodes` block of a
+// swarm_cluster. Nodes are addressed by their swarm advertise address except
+// for the node the swarm manager is switched to which keeps publicAddress.
+func flattenNodes(nodes []Node, currentNodeID, publicAddress string) []interface{} {
+	result := make([]interface{}, len(nodes))
+
+	for i, node := range nodes {
+		tags := map[string]interface{}{
+			swarm.RoleTag: node.Spec.Role,
+		}
+
+		address := node.Status.Addr
+		if node.ManagerStatus != nil {
+			if host, _, err := net.SplitHostPort(node.ManagerStatus.Addr); err == nil {
+				address = host
+			}
+		}
+
+		public := address
+		if node.ID == currentNodeID && publicAddress != "" {
+			public = publicAddress
+		}
+
+		// Nothing is configured yet when importing so every label of the
+		// node is brought under management
+		labels := make(map[string]interface{}, len(node.Spec.Labels))
+		for k, v := range node.Spec.Labels {
+			labels[k] = v
+		}
+
+		result[i] = refreshNode(map[string]interface{}{
+			"hostname":        node.Description.Hostname,
+			"public_address":  public,
+			"private_address": address,
+			"tags":            tags,
+			"labels":          labels,
+		}, node)
+	}
+
+	return result
+}
+
+// refreshNode updates an element of the `nodes` block with the actual role,
+// labels and status of the swarm node it describes. Labels set by the
+// `labels` tag are reported in the tag, all other labels in `labels`.
+func refreshNode(vm map[string]interface{}, node Node) map[string]interface{} {
+	tags := make(map[string]interface{})
+	for k, v := range vm["tags"].(map[string]interface{}) {
+		tags[k] = v
+	}
+
+	tags[swarm.RoleTag] = node.Spec.Role
+
+	configured, _ := vm["labels"].(map[string]interface{})
+
+	tag, _ := tags[swarm.LabelsTag].(string)
+	tagged := decodeLabels(tag)
+
+	// Tag labels overridden by `labels` are left as configured
+	kept := make(map[string]string)
+	for k, v := range tagged {
+		if _, ok := configured[k]; ok {
+			kept[k] = v
+		} else if actual, ok := node.Spec.Labels[k]; ok {
+			kept[k] = actual
+		}
+	}
+
+	// Only configured labels are reported, others may be managed by
+	// swarm_node or out of band
+	labels := make(map[string]interface{})
+	for k := range configured {
+		if actual, ok := node.Spec.Labels[k]; ok {
+			labels[k] = actual
+		}
+	}
+
+	if tag != "" && !labelsEqual(tag, kept) {
+		tags[swarm.LabelsTag] = encodeLabels(kept)
+	}
+
+	reachability := ""
+	if node.ManagerStatus != nil {
+		reachability = node.ManagerStatus.Reachability
+	}
+
+	return map[string]interface{}{
+		"hostname":        vm["hostname"],
+		"public_address":  vm["public_address"],
+		"private_address": vm["private_address"],
+		"tags":            tags,
+		"host_key":        vm["host_key"],
+		"ssh_user":        vm["ssh_user"],
+		"ssh_port":        vm["ssh_port"],
+		"ssh_key":         vm["ssh_key"],
+		"ssh_address":     vm["ssh_address"],
+		"labels":          labels,
+		"node_id":         node.ID,
+		"status":          node.Status.State,
+		"reachability":    reachability,
+	}
+}
+
+func resourceClusterCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	force := d.Get("skip_manager_validation").(bool)
+
+	vmnodes := expandVMNodes(d.Get("nodes").(*schema.Set).List())
+
 	managers := vmnodes.FilterByTag(swarm.RoleTag, swarm.ManagerRole)
 	if len(managers) == 0 {
 		diags = append(diags, diag.Diagnostic{
@@ -118,16 +522,11 @@ func resourceClusterCreate(ctx context.Context, d *schema.ResourceData, m interf
 		return diags
 	}
 
+	configureNodes(swarmManager, d.Get("nodes").(*schema.Set).List())
+
 	if swarmManager.Runner() == nil {
-		if err := swarmManager.SwitchNode(managers[0].PublicAddress); err != nil {
-			diags = append(diags, diag.Diagnostic{
-				Severity: diag.Error,
-				Summary:  "Unable to switch to first manager node",
-				Detail: fmt.Sprintf(
-					"Error switching to first manager node %s via %s: %s",
-					managers[0].Hostname, managers[0].PublicAddress, err.Error(),
-				),
-			})
+		if err := switchNode(swarmManager, managers[0].PublicAddress); err != nil {
+			diags = append(diags, switchDiagnostic(diag.Error, managers[0], err))
 			return diags
 		}
 	}
@@ -178,14 +577,23 @@ func resourceClusterCreate(ctx context.Context, d *schema.ResourceData, m interf
 			})
 			return diags
 		}
-
-		if err := d.Set("created_at", time.Now().Format(time.RFC3339)); err != nil {
-			return diag.FromErr(err)
-		}
 	}
 
 	d.SetId(node.Swarm.Cluster.ID)
 
+	if err := d.Set("created_at", formatTimestamp(node.Swarm.Cluster.CreatedAt)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := reconcileLabels(swarmManager, nil, vmnodes); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to label swarm nodes",
+			Detail:   fmt.Sprintf("Unable to label swarm nodes: %s", err),
+		})
+		return diags
+	}
+
 	resourceClusterRead(ctx, d, m)
 
 	return diags
@@ -197,36 +605,22 @@ func resourceClusterRead(ctx context.Context, d *schema.ResourceData, m interfac
 
 	swarmManager := m.(*swarm.Manager)
 
-	nodes := d.Get("nodes").([]interface{})
-	vmnodes := make(swarm.VMNodes, len(nodes))
-
-	for i, node := range nodes {
-		node := node.(map[string]interface{})
-		tags := make(map[string]string)
-		for k, v := range node["tags"].(map[string]interface{}) {
-			tags[k] = v.(string)
-		}
+	vmnodes := expandVMNodes(d.Get("nodes").(*schema.Set).List())
 
-		vmnodes[i] = swarm.VMNode{
-			Hostname:       node["hostname"].(string),
-			PublicAddress:  node["public_address"].(string),
-			PrivateAddress: node["private_address"].(string),
-			Tags:           tags,
-		}
-	}
+	configureNodes(swarmManager, d.Get("nodes").(*schema.Set).List())
 
 	if swarmManager.Runner() == nil {
 		managers := vmnodes.FilterByTag(swarm.RoleTag, swarm.ManagerRole)
 
-		if err := swarmManager.SwitchNode(managers[0].PublicAddress); err != nil {
-			diags = append(diags, diag.Diagnostic{
-				Severity: diag.Warning,
-				Summary:  "Unable to switch to first manager node",
-				Detail: fmt.Sprintf(
-					"Error switching to first manager node %s via %s: %s",
-					managers[0].Hostname, managers[0].PublicAddress, err.Error(),
-				),
-			})
+		if err := switchNode(swarmManager, managers[0].PublicAddress); err != nil {
+			// The node is reachable but cannot be trusted
+			var hostKeyErr *hostKeyError
+			if errors.As(err, &hostKeyErr) {
+				diags = append(diags, switchDiagnostic(diag.Error, managers[0], err))
+				return diags
+			}
+
+			diags = append(diags, switchDiagnostic(diag.Warning, managers[0], err))
 
 			// TODO: Really need to see if we can figure our a more reliable
 			//       way to identity whether the underlying machines on which
@@ -237,6 +631,16 @@ func resourceClusterRead(ctx context.Context, d *schema.ResourceData, m interfac
 		}
 	}
 
+	// Managers of an autolocked swarm are locked after they restart
+	if err := unlockNode(swarmManager, d.Get("unlock_key").(string)); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to unlock swarm manager",
+			Detail:   fmt.Sprintf("Unable to unlock swarm manager: %s", err),
+		})
+		return diags
+	}
+
 	node, err := swarmManager.GetInfo()
 	if err != nil {
 		diags = append(diags, diag.Diagnostic{
@@ -252,11 +656,119 @@ func resourceClusterRead(ctx context.Context, d *schema.ResourceData, m interfac
 
 	d.SetId(node.Swarm.Cluster.ID)
 
-	dataSourceClusterRead(ctx, d, m)
+	if err := d.Set("created_at", formatTimestamp(node.Swarm.Cluster.CreatedAt)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	nodes, err := getNodes(swarmManager)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to list swarm nodes",
+			Detail: fmt.Sprintf(
+				"Error listing nodes from %s: %s",
+				swarmManager.Switcher().String(), err.Error(),
+			),
+		})
+		return diags
+	}
+
+	members := make(map[string]Node)
+	for _, member := range nodes {
+		members[member.Description.Hostname] = member
+	}
+
+	// Nodes that are no longer part of the cluster (or have left it and are
+	// reported as down) are dropped from state so they are joined again.
+	var current []interface{}
+	for _, vm := range d.Get("nodes").(*schema.Set).List() {
+		vm := vm.(map[string]interface{})
+		hostname := vm["hostname"].(string)
+
+		member, ok := members[hostname]
+		if !ok || member.Status.State == nodeStateDown {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Node missing from swarm cluster",
+				Detail: fmt.Sprintf(
+					"Node %s is not an active member of swarm cluster %s and will be joined again",
+					hostname, node.Swarm.Cluster.ID,
+				),
+			})
+			continue
+		}
+
+		current = append(current, refreshNode(vm, member))
+	}
+
+	if err := d.Set("nodes", current); err != nil {
+		return diag.FromErr(err)
+	}
 
 	return diags
 }
 
+// resourceClusterImport imports an existing swarm cluster given the address
+// of one of its managers in the form `<manager_address>` or
+// `<cluster_id>/<manager_address>`.
+func resourceClusterImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	swarmManager := m.(*swarm.Manager)
+
+	var clusterID, address string
+
+	parts := strings.SplitN(d.Id(), "/", 2)
+	if len(parts) == 2 {
+		clusterID, address = parts[0], parts[1]
+	} else {
+		address = parts[0]
+	}
+
+	if address == "" {
+		return nil, fmt.Errorf("error invalid import id %q, expected <manager_address> or <cluster_id>/<manager_address>", d.Id())
+	}
+
+	if err := switchNode(swarmManager, address); err != nil {
+		return nil, fmt.Errorf("error switching to manager node %s: %w", address, err)
+	}
+
+	node, err := swarmManager.GetInfo()
+	if err != nil {
+		return nil, fmt.Errorf("error getting node info from %s: %w", swarmManager.Switcher().String(), err)
+	}
+
+	if node.Swarm.Cluster.ID == "" {
+		return nil, fmt.Errorf("error node %s is not part of a swarm cluster", address)
+	}
+	if clusterID != "" && clusterID != node.Swarm.Cluster.ID {
+		return nil, fmt.Errorf(
+			"error node %s belongs to swarm cluster %s not %s",
+			address, node.Swarm.Cluster.ID, clusterID,
+		)
+	}
+	if !node.IsManager() {
+		return nil, fmt.Errorf("error node %s is not a swarm manager", address)
+	}
+
+	nodes, err := getNodes(swarmManager)
+	if err != nil {
+		return nil, fmt.Errorf("error listing nodes from %s: %w", swarmManager.Switcher().String(), err)
+	}
+
+	if err := d.Set("nodes", flattenNodes(nodes, node.Swarm.NodeID, address)); err != nil {
+		return nil, err
+	}
+	if err := d.Set("skip_manager_validation", false); err != nil {
+		return nil, err
+	}
+	if err := d.Set("created_at", formatTimestamp(node.Swarm.Cluster.CreatedAt)); err != nil {
+		return nil, err
+	}
+
+	d.SetId(node.Swarm.Cluster.ID)
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func resourceClusterUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	// Warning or errors can be collected in a slice type
 	var diags diag.Diagnostics
@@ -264,41 +776,70 @@ func resourceClusterUpdate(ctx context.Context, d *schema.ResourceData, m interf
 	swarmManager := m.(*swarm.Manager)
 
 	if d.HasChange("nodes") {
-		nodes := d.Get("nodes").([]interface{})
-		vmnodes := make(swarm.VMNodes, len(nodes))
-
-		for i, node := range nodes {
-			node := node.(map[string]interface{})
-			tags := make(map[string]string)
-			for k, v := range node["tags"].(map[string]interface{}) {
-				tags[k] = v.(string)
-			}
+		o, n := d.GetChange("nodes")
 
-			vmnodes[i] = swarm.VMNode{
-				Hostname:       node["hostname"].(string),
-				PublicAddress:  node["public_address"].(string),
-				PrivateAddress: node["private_address"].(string),
-				Tags:           tags,
-			}
+		configureNodes(swarmManager, o.(*schema.Set).List())
+		configureNodes(swarmManager, n.(*schema.Set).List())
+		oldNodes := expandVMNodes(o.(*schema.Set).List())
+		newNodes := expandVMNodes(n.(*schema.Set).List())
+
+		// Keep the previous nodes in state if the update fails
+		d.Partial(true)
+
+		changes := diffVMNodes(oldNodes, newNodes)
+
+		managers := len(oldNodes.FilterByTag(swarm.RoleTag, swarm.ManagerRole))
+		if err := changes.validateQuorum(managers); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Unable to update swarm cluster without losing quorum",
+				Detail: fmt.Sprintf(
+					"Refusing to update swarm cluster: %s. Please demote or remove managers over several applies.",
+					err,
+				),
+			})
+			return diags
+		}
+
+		drainTimeout, err := getDrainTimeout(d)
+		if err != nil {
+			return diag.FromErr(err)
 		}
 
-		if swarmManager.Runner() == nil {
-			managers := vmnodes.FilterByTag(swarm.RoleTag, swarm.ManagerRole)
+		// Any manager that remains a manager can carry out the update so an
+		// unreachable one does not prevent updating the cluster
+		var (
+			manager     swarm.VMNode
+			found       bool
+			switchDiags diag.Diagnostics
+		)
+		for _, kept := range changes.kept {
+			if err := switchNode(swarmManager, kept.PublicAddress); err != nil {
+				switchDiags = append(switchDiags, switchDiagnostic(diag.Warning, kept, err))
+				continue
+			}
 
-			if err := swarmManager.SwitchNode(managers[0].PublicAddress); err != nil {
-				diags = append(diags, diag.Diagnostic{
-					Severity: diag.Error,
-					Summary:  "Unable to switch to first manager node",
-					Detail: fmt.Sprintf(
-						"Error switching to first manager node %s via %s: %s",
-						managers[0].Hostname, managers[0].PublicAddress, err.Error(),
-					),
-				})
-				return diags
+			manager, found = kept, true
+			break
+		}
+		if !found {
+			for i := range switchDiags {
+				switchDiags[i].Severity = diag.Error
 			}
+			return append(diags, switchDiags...)
+		}
+		diags = append(diags, switchDiags...)
+
+		if err := unlockNode(swarmManager, d.Get("unlock_key").(string)); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Unable to unlock swarm manager",
+				Detail:   fmt.Sprintf("Unable to unlock swarm manager: %s", err),
+			})
+			return diags
 		}
 
-		if err := swarmManager.UpdateSwarm(vmnodes); err != nil {
+		if err := changes.apply(swarmManager, manager, drainTimeout); err != nil {
 			diags = append(diags, diag.Diagnostic{
 				Severity: diag.Error,
 				Summary:  "Unable to update swarm cluster",
@@ -307,6 +848,8 @@ func resourceClusterUpdate(ctx context.Context, d *schema.ResourceData, m interf
 			return diags
 		}
 
+		d.Partial(false)
+
 		if err := d.Set("updated_at", time.Now().Format(time.RFC3339)); err != nil {
 			return diag.FromErr(err)
 		}
@@ -319,11 +862,344 @@ func resourceClusterDelete(ctx context.Context, d *schema.ResourceData, m interf
 	// Warning or errors can be collected in a slice type
 	var diags diag.Diagnostics
 
-	diags = append(diags, diag.Diagnostic{
-		Severity: diag.Warning,
-		Summary:  "Swarm Cluster Destruction NOT IMPLEMENTED",
-		Detail:   "Swarm Cluster Destruction is not yet implemented",
-	})
+	swarmManager := m.(*swarm.Manager)
+
+	drainTimeout, err := getDrainTimeout(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	vmnodes := expandVMNodes(d.Get("nodes").(*schema.Set).List())
+
+	configureNodes(swarmManager, d.Get("nodes").(*schema.Set).List())
+
+	managers := vmnodes.FilterByTag(swarm.RoleTag, swarm.ManagerRole)
+	if len(managers) == 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "No managers found in cluster config",
+			Detail:   "At least one manager must exist in the cluster config to destroy the cluster! Please check your `nodes` configuration.",
+		})
+		return diags
+	}
+
+	// The manager every other node is demoted and removed from is kept until
+	// the very end. Any manager that can list the nodes will do so that an
+	// unreachable manager does not prevent destroying the cluster.
+	var (
+		lastManager swarm.VMNode
+		nodes       []Node
+		found       bool
+		switchDiags diag.Diagnostics
+	)
+	for _, manager := range managers {
+		if err := switchNode(swarmManager, manager.PublicAddress); err != nil {
+			switchDiags = append(switchDiags, switchDiagnostic(diag.Warning, manager, err))
+			continue
+		}
+
+		listed, err := getNodes(swarmManager)
+		if err != nil {
+			switchDiags = append(switchDiags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Unable to list swarm nodes",
+				Detail:   fmt.Sprintf("Error listing nodes from manager node %s: %s", manager.Hostname, err),
+			})
+			continue
+		}
+
+		lastManager, nodes, found = manager, listed, true
+		break
+	}
+	if !found {
+		for i := range switchDiags {
+			switchDiags[i].Severity = diag.Error
+		}
+		return append(diags, switchDiags...)
+	}
+	diags = append(diags, switchDiags...)
+
+	desired := make(map[string]bool)
+	for _, vm := range vmnodes {
+		desired[vm.Hostname] = true
+	}
+
+	members := make(map[string]Node)
+	for _, node := range nodes {
+		members[node.Description.Hostname] = node
+
+		if !desired[node.Description.Hostname] {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Unmanaged node left in swarm cluster",
+				Detail: fmt.Sprintf(
+					"Node %s is a member of the swarm cluster but not part of the `nodes` configuration and will not be removed",
+					node.Description.Hostname,
+				),
+			})
+		}
+	}
+
+	// Workers can be removed in any order without affecting the raft quorum.
+	// They are drained first so their tasks are stopped gracefully.
+	for _, vm := range vmnodes.FilterByTag(swarm.RoleTag, swarm.WorkerRole) {
+		node, ok := members[vm.Hostname]
+		if !ok {
+			continue
+		}
+
+		if err := removeNode(swarmManager, lastManager, vm, node, false, drainTimeout); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Unable to remove worker node",
+				Detail: fmt.Sprintf(
+					"Error removing worker node %s from swarm cluster: %s",
+					vm.Hostname, err.Error(),
+				),
+			})
+		}
+	}
+
+	// Managers are demoted one at a time so the remaining managers always
+	// hold a quorum. Any failure here stops the teardown as carrying on could
+	// leave the cluster without a leader. With the workers gone there is
+	// nowhere left to drain managers to.
+	for _, vm := range managers {
+		node, ok := members[vm.Hostname]
+		if !ok || vm.Hostname == lastManager.Hostname {
+			continue
+		}
+
+		if err := removeNode(swarmManager, lastManager, vm, node, true, 0); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Unable to remove manager node",
+				Detail: fmt.Sprintf(
+					"Error demoting and removing manager node %s from swarm cluster: %s",
+					vm.Hostname, err.Error(),
+				),
+			})
+			return diags
+		}
+	}
+
+	if diags.HasError() {
+		return diags
+	}
+
+	if _, err := runCmd(swarmManager, forceLeaveCommand); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to leave swarm cluster",
+			Detail: fmt.Sprintf(
+				"Error leaving swarm cluster on last manager node %s: %s",
+				lastManager.Hostname, err.Error(),
+			),
+		})
+		return diags
+	}
+
+	d.SetId("")
 
 	return diags
 }
+
+// removeNode drains the given node, demotes it if it is a manager, has it
+// leave the swarm and finally removes it from the node list of the cluster.
+// Draining is skipped if drainTimeout is zero. A node that is down can
+// neither be drained nor leave so it is only demoted and removed. The swarm
+// manager is switched back to lastManager before returning.
+func removeNode(swarmManager *swarm.Manager, lastManager swarm.VMNode, vm swarm.VMNode, node Node, demote bool, drainTimeout time.Duration) error {
+	down := node.Status.State == nodeStateDown
+
+	if err := switchNode(swarmManager, lastManager.PublicAddress); err != nil {
+		return fmt.Errorf("error switching to manager node %s: %w", lastManager.Hostname, err)
+	}
+
+	if drainTimeout > 0 && !down {
+		if err := drainNode(swarmManager, node.ID, drainTimeout); err != nil {
+			return fmt.Errorf("error draining node: %w", err)
+		}
+	}
+
+	if demote {
+		if _, err := runCmd(swarmManager, fmt.Sprintf(demoteCommand, node.ID)); err != nil {
+			return fmt.Errorf("error demoting node: %w", err)
+		}
+	}
+
+	if !down {
+		if err := switchNode(swarmManager, vm.PublicAddress); err != nil {
+			return fmt.Errorf("error switching to node %s: %w", vm.Hostname, err)
+		}
+
+		if _, err := runCmd(swarmManager, leaveCommand); err != nil {
+			return fmt.Errorf("error leaving swarm: %w", err)
+		}
+
+		if err := switchNode(swarmManager, lastManager.PublicAddress); err != nil {
+			return fmt.Errorf("error switching to manager node %s: %w", lastManager.Hostname, err)
+		}
+	}
+
+	if _, err := runCmd(swarmManager, fmt.Sprintf(removeCommand, node.ID)); err != nil {
+		return fmt.Errorf("error removing node: %w", err)
+	}
+
+	return nil
+}
+
+// configureNodes passes the ssh settings configured for the given elements
+// of the `nodes` block to the switcher for both their public and private
+// addresses
+func configureNodes(swarmManager *swarm.Manager, nodes []interface{}) {
+	switcher, ok := swarmManager.Switcher().(*sshSwitcher)
+	if !ok {
+		return
+	}
+
+	configs := make(map[string]nodeSSHConfig)
+	for _, node := range nodes {
+		node := node.(map[string]interface{})
+
+		config := nodeSSHConfig{}
+		config.hostKey, _ = node["host_key"].(string)
+		config.user, _ = node["ssh_user"].(string)
+		config.key, _ = node["ssh_key"].(string)
+		config.address, _ = node["ssh_address"].(string)
+		if port, _ := node["ssh_port"].(int); port != 0 {
+			config.port = strconv.Itoa(port)
+		}
+
+		if config == (nodeSSHConfig{}) {
+			continue
+		}
+
+		configs[node["public_address"].(string)] = config
+		configs[node["private_address"].(string)] = config
+	}
+
+	switcher.configureNodes(configs)
+}
+
+// switchDiagnostic returns the diagnostic for failing to switch to the
+// given manager, explaining host key verification failures.
+func switchDiagnostic(severity diag.Severity, manager swarm.VMNode, err error) diag.Diagnostic {
+	var hostKeyErr *hostKeyError
+	if errors.As(err, &hostKeyErr) {
+		return diag.Diagnostic{
+			Severity: severity,
+			Summary:  "Unable to verify host key of manager node",
+			Detail: fmt.Sprintf(
+				"Refusing to connect to manager node %s via %s: %s. If the host key changed legitimately update the node's host_key or the known_hosts file.",
+				manager.Hostname, manager.PublicAddress, hostKeyErr,
+			),
+		}
+	}
+
+	return diag.Diagnostic{
+		Severity: severity,
+		Summary:  "Unable to switch to manager node",
+		Detail: fmt.Sprintf(
+			"Error switching to manager node %s via %s: %s",
+			manager.Hostname, manager.PublicAddress, err.Error(),
+		),
+	}
+}
+
+// reconcileLabels applies the labels managed by the cluster, those of the
+// labels tag and `labels`, to each of the given nodes. Managed labels of the
+// previous nodes that are no longer configured are removed while labels set
+// by swarm_node or out of band are left alone.
+func reconcileLabels(swarmManager *swarm.Manager, previous, vmnodes swarm.VMNodes) error {
+	if err := ensureManager(swarmManager); err != nil {
+		return fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	nodes, err := getNodes(swarmManager)
+	if err != nil {
+		return fmt.Errorf("error listing nodes: %w", err)
+	}
+
+	members := make(map[string]Node)
+	for _, node := range nodes {
+		members[node.Description.Hostname] = node
+	}
+
+	managed := make(map[string]map[string]string)
+	for _, vm := range previous {
+		managed[vm.Hostname] = decodeLabels(vm.GetTag(swarm.LabelsTag))
+	}
+
+	for _, vm := range vmnodes {
+		node, ok := members[vm.Hostname]
+		if !ok {
+			continue
+		}
+
+		labels := mergeLabels(node.Spec.Labels, managed[vm.Hostname], decodeLabels(vm.GetTag(swarm.LabelsTag)))
+		if err := updateNode(swarmManager, node.ID, node.Spec, NodeSpec{Labels: labels}); err != nil {
+			return fmt.Errorf("error labelling node %s: %w", vm.Hostname, err)
+		}
+	}
+
+	return nil
+}
+
+// mergeLabels returns the current labels of a node with the previously
+// managed labels that are no longer desired removed and the desired labels
+// set
+func mergeLabels(current, previous, desired map[string]string) map[string]string {
+	labels := make(map[string]string)
+	for k, v := range current {
+		labels[k] = v
+	}
+
+	for k := range previous {
+		if _, ok := desired[k]; !ok {
+			delete(labels, k)
+		}
+	}
+
+	for k, v := range desired {
+		labels[k] = v
+	}
+
+	return labels
+}
+
+// getDrainTimeout returns how long to wait for nodes to drain before they
+// are removed from the cluster or zero if nodes should not be drained.
+func getDrainTimeout(d *schema.ResourceData) (time.Duration, error) {
+	if !d.Get("drain_before_remove").(bool) {
+		return 0, nil
+	}
+
+	drainTimeout := d.Get("drain_timeout").(string)
+	timeout, err := time.ParseDuration(drainTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing drain timeout %s: %w", drainTimeout, err)
+	}
+
+	return timeout, nil
+}
+
+// validateDuration validates that a value is a duration such as `10m`
+func validateDuration(v interface{}, k string) ([]string, []error) {
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("expected %s to be a duration such as 10m, got %q: %w", k, v, err)}
+	}
+	return nil, nil
+}
+
+// formatTimestamp formats a timestamp reported by docker as RFC3339, the
+// format of every timestamp set by the provider. Timestamps that cannot be
+// parsed are returned unchanged.
+func formatTimestamp(timestamp string) string {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return timestamp
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
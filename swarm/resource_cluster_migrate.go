@@ -0,0 +1,114 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceClusterV0 is the schema of swarm_cluster before `nodes` became a
+// set keyed by hostname. It is frozen as released and must not change.
+func resourceClusterV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"skip_manager_validation": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"nodes": {
+				Type:     schema.TypeList,
+				Required: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"hostname": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"public_address": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"private_address": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"tags": {
+							Type:     schema.TypeMap,
+							Required: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+			"created_at": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+			"updated_at": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// resourceClusterStateUpgradeV0 upgrades the positional `nodes` list to a
+// set. Both are stored as JSON arrays so the nodes are kept as they are,
+// but a set keyed by hostname cannot hold two nodes with the same hostname.
+// Attributes added since are set to their defaults.
+func resourceClusterStateUpgradeV0(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	if _, ok := rawState["drain_before_remove"]; !ok {
+		rawState["drain_before_remove"] = true
+	}
+	if _, ok := rawState["drain_timeout"]; !ok {
+		rawState["drain_timeout"] = "10m"
+	}
+
+	nodes, ok := rawState["nodes"].([]interface{})
+	if !ok {
+		return rawState, nil
+	}
+
+	seen := make(map[string]bool)
+
+	for _, node := range nodes {
+		node, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error upgrading state: unexpected node %v", node)
+		}
+
+		hostname, _ := node["hostname"].(string)
+		if seen[hostname] {
+			return nil, fmt.Errorf(
+				"error upgrading state: more than one node has the hostname %q, remove the duplicates with the previous version of the provider first",
+				hostname,
+			)
+		}
+		seen[hostname] = true
+	}
+
+	return rawState, nil
+}
@@ -0,0 +1,149 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// testClusterStateV0JSON is the state of a swarm_cluster as written by the
+// first release of the provider
+const testClusterStateV0JSON = `{
+	"created_at": "2021-11-01T00:00:00Z",
+	"id": "um5m2mo3nmi10c8kyh733bafv",
+	"nodes": [
+		{
+			"hostname": "manager1",
+			"private_address": "192.168.0.1",
+			"public_address": "10.0.0.1",
+			"tags": {"role": "manager"}
+		},
+		{
+			"hostname": "worker1",
+			"private_address": "192.168.0.2",
+			"public_address": "10.0.0.2",
+			"tags": {"role": "worker", "labels": "zone=a"}
+		}
+	],
+	"skip_manager_validation": false,
+	"updated_at": ""
+}`
+
+func testClusterStateV0(t *testing.T) map[string]interface{} {
+	var rawState map[string]interface{}
+	if err := json.Unmarshal([]byte(testClusterStateV0JSON), &rawState); err != nil {
+		t.Fatal(err)
+	}
+	return rawState
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func schemaKeys(s map[string]*schema.Schema, extra ...string) []string {
+	keys := append(make([]string, 0, len(s)+len(extra)), extra...)
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestResourceClusterV0Schema(t *testing.T) {
+	if err := resourceClusterV0().InternalValidate(nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	rawState := testClusterStateV0(t)
+
+	// The frozen schema must describe exactly the released state
+	v0 := resourceClusterV0()
+	if keys, expected := schemaKeys(v0.Schema, "id"), sortedKeys(rawState); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected v0 attributes %q got %q", expected, keys)
+	}
+	node := rawState["nodes"].([]interface{})[0].(map[string]interface{})
+	if keys, expected := schemaKeys(v0.Schema["nodes"].Elem.(*schema.Resource).Schema), sortedKeys(node); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected v0 node attributes %q got %q", expected, keys)
+	}
+
+	if _, err := schema.JSONMapToStateValue(rawState, v0.CoreConfigSchema()); err != nil {
+		t.Errorf("expected the released state to decode with the v0 schema got %s", err)
+	}
+}
+
+func TestResourceClusterStateUpgradeV0(t *testing.T) {
+	actual, err := resourceClusterStateUpgradeV0(context.Background(), testClusterStateV0(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := testClusterStateV0(t)
+	expected["drain_before_remove"] = true
+	expected["drain_timeout"] = "10m"
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v got %v", expected, actual)
+	}
+
+	state, err := schema.JSONMapToStateValue(actual, resourceCluster().CoreConfigSchema())
+	if err != nil {
+		t.Fatalf("expected the upgraded state to decode with the current schema got %s", err)
+	}
+	if nodes := state.GetAttr("nodes"); !nodes.Type().IsSetType() || nodes.LengthInt() != 2 {
+		t.Errorf("expected a set of 2 nodes got %#v", nodes)
+	}
+}
+
+func TestResourceClusterStateUpgradeV0DuplicateHostnames(t *testing.T) {
+	rawState := testClusterStateV0(t)
+	nodes := rawState["nodes"].([]interface{})
+	rawState["nodes"] = append(nodes, map[string]interface{}{
+		"hostname":        "worker1",
+		"private_address": "192.168.0.3",
+		"public_address":  "10.0.0.3",
+		"tags":            map[string]interface{}{"role": "worker"},
+	})
+
+	if _, err := resourceClusterStateUpgradeV0(context.Background(), rawState, nil); err == nil {
+		t.Error("expected an error for duplicate hostnames")
+	}
+}
+
+func TestResourceClusterStateUpgradeV0InvalidNode(t *testing.T) {
+	rawState := testClusterStateV0(t)
+	rawState["nodes"] = []interface{}{fmt.Sprint("manager1")}
+
+	if _, err := resourceClusterStateUpgradeV0(context.Background(), rawState, nil); err == nil {
+		t.Error("expected an error for an invalid node")
+	}
+}
@@ -0,0 +1,365 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/aucloud/go-swarm"
+)
+
+const (
+	// defaultDispatcherHeartbeat and defaultCertExpiry are the settings of a
+	// swarm initialised without --dispatcher-heartbeat or --cert-expiry
+	defaultDispatcherHeartbeat = "5s"
+	defaultCertExpiry          = "2160h0m0s"
+)
+
+// resourceClusterSpec manages the settings of the swarm cluster the provider
+// is connected to. Destroying it leaves the settings unchanged.
+func resourceClusterSpec() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceClusterSpecCreate,
+		ReadContext:   resourceClusterSpecRead,
+		UpdateContext: resourceClusterSpecUpdate,
+		DeleteContext: resourceClusterSpecDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		CustomizeDiff: resourceClusterSpecCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"task_history_limit": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Computed: true,
+			},
+			"snapshot_interval": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Computed: true,
+			},
+			"max_snapshots": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Computed: true,
+			},
+			// Not settable through the docker CLI so only reported
+			"log_entries_for_slow_followers": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"dispatcher_heartbeat": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          defaultDispatcherHeartbeat,
+				ValidateFunc:     validateDuration,
+				DiffSuppressFunc: suppressEquivalentDurations,
+			},
+			"cert_expiry": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          defaultCertExpiry,
+				ValidateFunc:     validateDuration,
+				DiffSuppressFunc: suppressEquivalentDurations,
+			},
+			"external_ca": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"protocol": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "cfssl",
+						},
+						"url": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+					},
+				},
+			},
+			"autolock": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Computed: true,
+			},
+			"unlock_key_triggers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"unlock_key": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			// Only chosen when the swarm is initialised so only reported
+			"default_addr_pool": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"subnet_size": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"data_path_port": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// resourceClusterSpecCustomizeDiff refuses to remove every external CA as
+// `docker swarm update` can only replace them with at least one other.
+func resourceClusterSpecCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if d.Id() == "" || !d.HasChange("external_ca") {
+		return nil
+	}
+
+	if len(d.Get("external_ca").([]interface{})) == 0 {
+		return fmt.Errorf("error external_ca cannot be removed once set, the docker CLI can only replace the external CAs")
+	}
+
+	return nil
+}
+
+// clusterSpecFlags returns the `docker swarm update` flags for the changed
+// settings of the swarm_cluster_spec.
+func clusterSpecFlags(d *schema.ResourceData) []string {
+	var flags []string
+
+	for _, setting := range []struct{ key, flag string }{
+		{"task_history_limit", "--task-history-limit"},
+		{"snapshot_interval", "--snapshot-interval"},
+		{"max_snapshots", "--max-snapshots"},
+	} {
+		if d.HasChange(setting.key) {
+			flags = append(flags, fmt.Sprintf("%s %d", setting.flag, d.Get(setting.key).(int)))
+		}
+	}
+
+	for _, setting := range []struct{ key, flag string }{
+		{"dispatcher_heartbeat", "--dispatcher-heartbeat"},
+		{"cert_expiry", "--cert-expiry"},
+	} {
+		if d.HasChange(setting.key) {
+			flags = append(flags, fmt.Sprintf("%s %s", setting.flag, shellQuote(d.Get(setting.key).(string))))
+		}
+	}
+
+	if d.HasChange("external_ca") {
+		for _, ca := range d.Get("external_ca").([]interface{}) {
+			ca := ca.(map[string]interface{})
+			flags = append(flags, fmt.Sprintf(
+				"--external-ca %s",
+				shellQuote(fmt.Sprintf("protocol=%s,url=%s", ca["protocol"], ca["url"])),
+			))
+		}
+	}
+
+	if d.HasChange("autolock") {
+		flags = append(flags, fmt.Sprintf("--autolock=%t", d.Get("autolock").(bool)))
+	}
+
+	return flags
+}
+
+// updateClusterSpec applies the changed settings of the swarm_cluster_spec
+// and rotates the unlock key when its triggers change.
+func updateClusterSpec(swarmManager *swarm.Manager, d *schema.ResourceData) error {
+	if flags := clusterSpecFlags(d); len(flags) > 0 {
+		if _, err := runCmd(swarmManager, fmt.Sprintf(clusterUpdateCommand, strings.Join(flags, " "))); err != nil {
+			return fmt.Errorf("error running swarm update command: %w", err)
+		}
+	}
+
+	// A new unlock key is generated when autolock is enabled
+	if !d.IsNewResource() && !d.HasChange("autolock") && d.HasChange("unlock_key_triggers") && d.Get("autolock").(bool) {
+		if _, err := getUnlockKey(swarmManager, true); err != nil {
+			return fmt.Errorf("error rotating unlock key: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func resourceClusterSpecCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	cluster, err := inspectCluster(swarmManager)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error inspecting swarm cluster: %w", err))
+	}
+
+	if err := updateClusterSpec(swarmManager, d); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to update swarm cluster settings",
+			Detail:   fmt.Sprintf("Unable to update settings of swarm cluster %s: %s", cluster.ID, err),
+		})
+		return diags
+	}
+
+	d.SetId(cluster.ID)
+
+	return resourceClusterSpecRead(ctx, d, m)
+}
+
+func resourceClusterSpecRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := unlockNode(swarmManager, d.Get("unlock_key").(string)); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to unlock swarm manager",
+			Detail:   fmt.Sprintf("Unable to unlock swarm manager: %s", err),
+		})
+		return diags
+	}
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	cluster, err := inspectCluster(swarmManager)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error inspecting swarm cluster: %w", err))
+	}
+
+	// The settings belong to a swarm cluster that no longer exists
+	if cluster.ID != d.Id() {
+		d.SetId("")
+		return diags
+	}
+
+	spec := cluster.Spec
+
+	var taskHistoryLimit int64
+	if spec.Orchestration.TaskHistoryRetentionLimit != nil {
+		taskHistoryLimit = *spec.Orchestration.TaskHistoryRetentionLimit
+	}
+
+	var maxSnapshots uint64
+	if spec.Raft.KeepOldSnapshots != nil {
+		maxSnapshots = *spec.Raft.KeepOldSnapshots
+	}
+
+	externalCAs := make([]interface{}, len(spec.CAConfig.ExternalCAs))
+	for i, ca := range spec.CAConfig.ExternalCAs {
+		externalCAs[i] = map[string]interface{}{
+			"protocol": ca.Protocol,
+			"url":      ca.URL,
+		}
+	}
+
+	settings := map[string]interface{}{
+		"task_history_limit":             int(taskHistoryLimit),
+		"snapshot_interval":              int(spec.Raft.SnapshotInterval),
+		"max_snapshots":                  int(maxSnapshots),
+		"log_entries_for_slow_followers": int(spec.Raft.LogEntriesForSlowFollowers),
+		"dispatcher_heartbeat":           formatDuration(spec.Dispatcher.HeartbeatPeriod),
+		"cert_expiry":                    formatDuration(spec.CAConfig.NodeCertExpiry),
+		"external_ca":                    externalCAs,
+		"autolock":                       spec.EncryptionConfig.AutoLockManagers,
+		"default_addr_pool":              cluster.DefaultAddrPool,
+		"subnet_size":                    int(cluster.SubnetSize),
+		"data_path_port":                 int(cluster.DataPathPort),
+	}
+
+	var unlockKey string
+	if spec.EncryptionConfig.AutoLockManagers {
+		unlockKey, err = getUnlockKey(swarmManager, false)
+		if err != nil {
+			return diag.FromErr(fmt.Errorf("error getting unlock key: %w", err))
+		}
+	}
+	settings["unlock_key"] = unlockKey
+
+	for key, value := range settings {
+		if err := d.Set(key, value); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diags
+}
+
+func resourceClusterSpecUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := unlockNode(swarmManager, d.Get("unlock_key").(string)); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to unlock swarm manager",
+			Detail:   fmt.Sprintf("Unable to unlock swarm manager: %s", err),
+		})
+		return diags
+	}
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	if err := updateClusterSpec(swarmManager, d); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to update swarm cluster settings",
+			Detail:   fmt.Sprintf("Unable to update settings of swarm cluster %s: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	return resourceClusterSpecRead(ctx, d, m)
+}
+
+func resourceClusterSpecDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	// The cluster keeps its settings, removing the resource only forgets it
+	d.SetId("")
+
+	return diags
+}
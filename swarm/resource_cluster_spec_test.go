@@ -0,0 +1,72 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestClusterSpecFlags(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   map[string]interface{}
+		expected []string
+	}{
+		{
+			name:   "defaults",
+			config: map[string]interface{}{},
+			expected: []string{
+				"--dispatcher-heartbeat '5s'",
+				"--cert-expiry '2160h0m0s'",
+			},
+		},
+		{
+			name: "settings",
+			config: map[string]interface{}{
+				"task_history_limit":   10,
+				"dispatcher_heartbeat": "10s",
+				"cert_expiry":          "720h",
+				"external_ca": []interface{}{
+					map[string]interface{}{"url": "https://ca.example.com"},
+				},
+				"autolock": true,
+			},
+			expected: []string{
+				"--task-history-limit 10",
+				"--dispatcher-heartbeat '10s'",
+				"--cert-expiry '720h'",
+				"--external-ca 'protocol=cfssl,url=https://ca.example.com'",
+				"--autolock=true",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, resourceClusterSpec().Schema, tc.config)
+			if flags := clusterSpecFlags(d); !reflect.DeepEqual(flags, tc.expected) {
+				t.Errorf("expected flags %q got %q", tc.expected, flags)
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/aucloud/go-swarm"
+)
+
+func TestMergeLabels(t *testing.T) {
+	current := map[string]string{"zone": "a", "disk": "ssd", "team": "web"}
+	previous := map[string]string{"zone": "a", "disk": "ssd"}
+	desired := map[string]string{"zone": "b"}
+
+	expected := map[string]string{"zone": "b", "team": "web"}
+	if actual := mergeLabels(current, previous, desired); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v got %v", expected, actual)
+	}
+}
+
+func TestExpandVMNodes(t *testing.T) {
+	nodes := []interface{}{
+		map[string]interface{}{
+			"hostname":        "manager1",
+			"public_address":  "10.0.0.1",
+			"private_address": "192.168.0.1",
+			"tags":            map[string]interface{}{"role": "manager", "labels": "zone=a&disk=ssd"},
+			"labels":          map[string]interface{}{"zone": "b", "team": "web"},
+		},
+		map[string]interface{}{
+			"hostname":        "worker1",
+			"public_address":  "10.0.0.2",
+			"private_address": "192.168.0.2",
+			"tags":            map[string]interface{}{"role": "worker", "labels": "zone=a"},
+			"labels":          map[string]interface{}{},
+		},
+	}
+
+	expected := swarm.VMNodes{
+		{
+			Hostname:       "manager1",
+			PublicAddress:  "10.0.0.1",
+			PrivateAddress: "192.168.0.1",
+			Tags:           map[string]string{"role": "manager", "labels": "disk=ssd&team=web&zone=b"},
+		},
+		{
+			Hostname:       "worker1",
+			PublicAddress:  "10.0.0.2",
+			PrivateAddress: "192.168.0.2",
+			Tags:           map[string]string{"role": "worker", "labels": "zone=a"},
+		},
+	}
+
+	if actual := expandVMNodes(nodes); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v got %+v", expected, actual)
+	}
+}
+
+func TestConfigureNodes(t *testing.T) {
+	switcher, err := newSSHSwitcher(sshConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	swarmManager, err := swarm.NewManager(switcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configureNodes(swarmManager, []interface{}{
+		map[string]interface{}{
+			"public_address":  "10.0.0.1",
+			"private_address": "192.168.0.1",
+			"host_key":        "SHA256:abc",
+			"ssh_user":        "admin",
+			"ssh_key":         "key",
+			"ssh_address":     "203.0.113.1",
+			"ssh_port":        2222,
+		},
+		map[string]interface{}{
+			"public_address":  "10.0.0.2",
+			"private_address": "192.168.0.2",
+			"host_key":        "",
+			"ssh_user":        "",
+			"ssh_key":         "",
+			"ssh_address":     "",
+			"ssh_port":        0,
+		},
+	})
+
+	config := nodeSSHConfig{user: "admin", port: "2222", key: "key", address: "203.0.113.1", hostKey: "SHA256:abc"}
+	expected := map[string]nodeSSHConfig{"10.0.0.1": config, "192.168.0.1": config}
+	if !reflect.DeepEqual(switcher.nodes, expected) {
+		t.Errorf("expected %+v got %+v", expected, switcher.nodes)
+	}
+}
+
+func TestRefreshNodeLabels(t *testing.T) {
+	vm := map[string]interface{}{
+		"hostname":        "worker1",
+		"public_address":  "10.0.0.1",
+		"private_address": "192.168.0.1",
+		"tags":            map[string]interface{}{"role": "worker"},
+		"labels":          map[string]interface{}{"zone": "a", "missing": "x"},
+	}
+
+	node := Node{ID: "node1", Spec: NodeSpec{Role: "worker", Labels: map[string]string{"zone": "b", "team": "web"}}}
+
+	expected := map[string]interface{}{"zone": "b"}
+	if actual := refreshNode(vm, node)["labels"]; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v got %v", expected, actual)
+	}
+}
+
+func TestFlattenNodesImportsLabels(t *testing.T) {
+	manager := Node{ID: "node1", Spec: NodeSpec{Role: "manager", Labels: map[string]string{"zone": "a", "team": "web"}}}
+	manager.Description.Hostname = "manager1"
+	manager.Status.Addr = "192.168.0.1"
+	manager.ManagerStatus = &ManagerStatus{Addr: "192.168.0.1:2377"}
+
+	worker := Node{ID: "node2", Spec: NodeSpec{Role: "worker"}}
+	worker.Description.Hostname = "worker1"
+	worker.Status.Addr = "192.168.0.2"
+
+	nodes := flattenNodes([]Node{manager, worker}, "node1", "10.0.0.1")
+
+	for i, expected := range []map[string]interface{}{
+		{"zone": "a", "team": "web"},
+		{},
+	} {
+		node := nodes[i].(map[string]interface{})
+		if actual := node["labels"]; !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected labels of %s to be %v got %v", node["hostname"], expected, actual)
+		}
+	}
+
+	if actual := nodes[0].(map[string]interface{})["public_address"]; actual != "10.0.0.1" {
+		t.Errorf("expected the public address of the current node to be kept got %v", actual)
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	testCases := []struct {
+		timestamp string
+		expected  string
+	}{
+		{"2021-11-01T10:11:12.123456789Z", "2021-11-01T10:11:12Z"},
+		{"2021-11-01T20:11:12.5+10:00", "2021-11-01T10:11:12Z"},
+		{"2021-11-01T10:11:12Z", "2021-11-01T10:11:12Z"},
+		{"", ""},
+		{"yesterday", "yesterday"},
+	}
+
+	for _, tc := range testCases {
+		if actual := formatTimestamp(tc.timestamp); actual != tc.expected {
+			t.Errorf("expected %q to be formatted as %q got %q", tc.timestamp, tc.expected, actual)
+		}
+	}
+}
+
+func TestPlannedNodes(t *testing.T) {
+	node := func(hostname string, tags cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"hostname":        cty.StringVal(hostname),
+			"public_address":  cty.UnknownVal(cty.String),
+			"private_address": cty.StringVal("10.0.0.1"),
+			"tags":            tags,
+		})
+	}
+
+	nodes := cty.ListVal([]cty.Value{
+		node("manager1", cty.MapVal(map[string]cty.Value{"role": cty.StringVal("manager")})),
+		node("worker1", cty.MapVal(map[string]cty.Value{"role": cty.UnknownVal(cty.String)})),
+		node("worker2", cty.UnknownVal(cty.Map(cty.String))),
+		node("worker3", cty.MapVal(map[string]cty.Value{"labels": cty.StringVal("zone=a")})),
+	})
+
+	expected := []plannedNode{
+		{hostname: "manager1", privateAddress: "10.0.0.1", role: "manager", roleKnown: true},
+		{hostname: "worker1", privateAddress: "10.0.0.1"},
+		{hostname: "worker2", privateAddress: "10.0.0.1"},
+		{hostname: "worker3", privateAddress: "10.0.0.1", roleKnown: true},
+	}
+
+	actual, ok := plannedNodes(nodes)
+	if !ok {
+		t.Fatal("expected nodes to be known")
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v got %+v", expected, actual)
+	}
+
+	if _, ok := plannedNodes(cty.UnknownVal(nodes.Type())); ok {
+		t.Error("expected unknown nodes not to be known")
+	}
+}
+
+func TestValidateNodes(t *testing.T) {
+	node := func(hostname, address, role string) plannedNode {
+		return plannedNode{hostname: hostname, publicAddress: address, privateAddress: address, role: role, roleKnown: true}
+	}
+	managers := []plannedNode{
+		node("manager1", "10.0.0.1", "manager"),
+		node("manager2", "manager2.example.com", "manager"),
+		node("manager3", "10.0.0.3", "manager"),
+		node("manager4", "10.0.0.4", "manager"),
+		node("manager5", "10.0.0.5", "manager"),
+		node("manager6", "10.0.0.6", "manager"),
+		node("manager7", "10.0.0.7", "manager"),
+	}
+
+	testCases := []struct {
+		name  string
+		nodes []plannedNode
+		skip  bool
+		valid bool
+	}{
+		{name: "hostnames and addresses", nodes: managers[:3], valid: true},
+		{
+			name:  "duplicate hostname",
+			nodes: append([]plannedNode{node("manager1", "10.0.0.8", "worker")}, managers[:3]...),
+		},
+		{
+			name:  "duplicate address",
+			nodes: append([]plannedNode{node("worker1", "manager2.example.com", "worker")}, managers[:3]...),
+		},
+		{
+			name:  "invalid address",
+			nodes: append([]plannedNode{node("worker1", "not an address", "worker")}, managers[:3]...),
+		},
+		{
+			name:  "invalid role",
+			nodes: append([]plannedNode{node("worker1", "10.0.0.8", "leader")}, managers[:3]...),
+		},
+		{name: "one manager", nodes: managers[:1], valid: true},
+		{name: "two managers", nodes: managers[:2]},
+		{name: "four managers", nodes: managers[:4]},
+		{name: "seven managers", nodes: managers, valid: true},
+		{name: "two managers skipping validation", nodes: managers[:2], skip: true, valid: true},
+		{name: "no managers", nodes: []plannedNode{node("worker1", "10.0.0.8", "worker")}, skip: true},
+		{
+			name:  "unknown role",
+			nodes: append([]plannedNode{{hostname: "worker1"}}, managers[:2]...),
+			valid: true,
+		},
+		{
+			name:  "invalid address with unknown role",
+			nodes: append([]plannedNode{{hostname: "worker1"}, node("worker2", "-", "worker")}, managers[:3]...),
+		},
+		{
+			name:  "unknown addresses",
+			nodes: append([]plannedNode{{hostname: "worker1", role: "worker", roleKnown: true}, {role: "worker", roleKnown: true}}, managers[:3]...),
+			valid: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateNodes(tc.nodes, tc.skip)
+			if tc.valid && err != nil {
+				t.Errorf("expected nodes to be valid got %s", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("expected nodes to be invalid")
+			}
+		})
+	}
+}
+
+// testClusterNodes returns the `nodes` block describing the given nodes
+func testClusterNodes(nodes ...Node) []interface{} {
+	result := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		result[i] = map[string]interface{}{
+			"hostname":        node.Description.Hostname,
+			"public_address":  node.Status.Addr,
+			"private_address": node.Status.Addr,
+			"tags":            map[string]interface{}{swarm.RoleTag: node.Spec.Role},
+		}
+	}
+	return result
+}
+
+func TestResourceClusterDeleteDrainsWorkers(t *testing.T) {
+	defer func(interval time.Duration) { drainPollInterval = interval }(drainPollInterval)
+	drainPollInterval = time.Millisecond
+
+	manager := testNode("m1", "manager1", "10.0.0.1", swarm.ManagerRole)
+	worker := testNode("w1", "worker1", "10.0.0.2", swarm.WorkerRole)
+
+	for _, drain := range []bool{true, false} {
+		switcher := newTestSwitcher(manager, worker)
+		swarmManager, err := swarm.NewManager(switcher)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := schema.TestResourceDataRaw(t, resourceCluster().Schema, map[string]interface{}{
+			"nodes":               testClusterNodes(manager, worker),
+			"drain_before_remove": drain,
+		})
+		d.SetId("cluster")
+
+		if diags := resourceClusterDelete(context.Background(), d, swarmManager); diags.HasError() {
+			t.Fatalf("expected cluster to be destroyed got %+v", diags)
+		}
+
+		drained := switcher.ran("10.0.0.1", "docker node update --availability drain w1")
+		left := switcher.ran("10.0.0.2", "docker swarm leave")
+		removed := switcher.ran("10.0.0.1", "docker node rm --force w1")
+
+		if left == -1 || removed < left {
+			t.Fatalf("expected worker to leave and then be removed got %q", switcher.commands)
+		}
+		if drain && (drained == -1 || drained > left) {
+			t.Errorf("expected worker to be drained before leaving got %q", switcher.commands)
+		}
+		if !drain && drained != -1 {
+			t.Errorf("expected worker not to be drained got %q", switcher.commands)
+		}
+		if switcher.ran("10.0.0.1", "docker swarm leave --force") == -1 {
+			t.Errorf("expected last manager to leave got %q", switcher.commands)
+		}
+	}
+}
+
+func TestResourceClusterUpdateUnreachableManager(t *testing.T) {
+	manager1 := testNode("m1", "manager1", "10.0.0.1", swarm.ManagerRole)
+	manager2 := testNode("m2", "manager2", "10.0.0.2", swarm.ManagerRole)
+	manager3 := testNode("m3", "manager3", "10.0.0.3", swarm.ManagerRole)
+	worker := testNode("w1", "worker1", "10.0.0.4", swarm.WorkerRole)
+
+	switcher := newTestSwitcher(manager1, manager2, manager3, worker)
+	switcher.unreachable["10.0.0.1"] = true
+	swarmManager, err := swarm.NewManager(switcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := resourceCluster()
+	state := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"nodes":               testClusterNodes(manager1, manager2, manager3, worker),
+		"drain_before_remove": false,
+	})
+	state.SetId("cluster")
+
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"nodes":               testClusterNodes(manager1, manager2, manager3),
+		"drain_before_remove": false,
+	})
+	diff, err := schema.InternalMap(r.Schema).Diff(context.Background(), state.State(), config, nil, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := schema.InternalMap(r.Schema).Data(state.State(), diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags := resourceClusterUpdate(context.Background(), d, swarmManager)
+	if switcher.ran("10.0.0.2", "docker node rm --force w1") == -1 {
+		t.Fatalf("expected worker to be removed via the next manager got %q %+v", switcher.commands, diags)
+	}
+	if diags.HasError() {
+		t.Errorf("expected cluster to be updated got %+v", diags)
+	}
+	if len(diags) == 0 || diags[0].Severity != diag.Warning {
+		t.Errorf("expected a warning for the unreachable manager got %+v", diags)
+	}
+}
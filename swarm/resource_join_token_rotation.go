@@ -0,0 +1,169 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/aucloud/go-swarm"
+)
+
+// resourceJoinTokenRotation rotates the join tokens of the swarm cluster
+// whenever it is created, which is every time its triggers change.
+func resourceJoinTokenRotation() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceJoinTokenRotationCreate,
+		ReadContext:   resourceJoinTokenRotationRead,
+		DeleteContext: resourceJoinTokenRotationDelete,
+		Schema: map[string]*schema.Schema{
+			"triggers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"rotate_worker_token": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  true,
+			},
+			"rotate_manager_token": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  true,
+			},
+			"worker_token": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"manager_token": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"rotated_at": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceJoinTokenRotationCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	node, err := swarmManager.GetInfo()
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error getting node info: %w", err))
+	}
+
+	var roles []string
+	if d.Get("rotate_worker_token").(bool) {
+		roles = append(roles, swarm.WorkerRole)
+	}
+	if d.Get("rotate_manager_token").(bool) {
+		roles = append(roles, swarm.ManagerRole)
+	}
+
+	if len(roles) == 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "No join tokens to rotate",
+			Detail:   "At least one of rotate_worker_token or rotate_manager_token must be true.",
+		})
+		return diags
+	}
+
+	for _, role := range roles {
+		if _, err := runCmd(swarmManager, fmt.Sprintf(rotateTokenCommand, role)); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Unable to rotate join token",
+				Detail:   fmt.Sprintf("Unable to rotate %s join token: %s", role, err),
+			})
+			return diags
+		}
+	}
+
+	if err := d.Set("rotated_at", time.Now().Format(time.RFC3339)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(node.Swarm.Cluster.ID)
+
+	return resourceJoinTokenRotationRead(ctx, d, m)
+}
+
+func resourceJoinTokenRotationRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	workerToken, err := swarmManager.JoinToken(swarm.WorkerRole)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error getting worker join token: %w", err))
+	}
+
+	managerToken, err := swarmManager.JoinToken(swarm.ManagerRole)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error getting manager join token: %w", err))
+	}
+
+	if err := d.Set("worker_token", workerToken); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("manager_token", managerToken); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+func resourceJoinTokenRotationDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	// Tokens cannot be un-rotated, removing the resource only forgets it
+	d.SetId("")
+
+	return diags
+}
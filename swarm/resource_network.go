@@ -0,0 +1,265 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/aucloud/go-swarm"
+)
+
+const (
+	encryptedOption = "encrypted"
+)
+
+// resourceNetwork manages swarm networks. Networks cannot be modified once
+// created so every change to a swarm_network replaces the network.
+func resourceNetwork() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceNetworkCreate,
+		ReadContext:   resourceNetworkRead,
+		DeleteContext: resourceNetworkDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"driver": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				Default:  "overlay",
+			},
+			"ipam_config": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"subnet": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Computed: true,
+							ForceNew: true,
+						},
+						"gateway": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Computed: true,
+							ForceNew: true,
+						},
+						"ip_range": {
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: true,
+						},
+					},
+				},
+			},
+			"attachable": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  false,
+			},
+			"internal": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  false,
+			},
+			"encrypted": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  false,
+			},
+			"ingress": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  false,
+			},
+			"labels": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				ForceNew: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"scope": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceNetworkCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	flags := []string{"--driver", shellQuote(d.Get("driver").(string))}
+
+	for _, config := range d.Get("ipam_config").([]interface{}) {
+		config, ok := config.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key, flag := range map[string]string{"subnet": "--subnet", "gateway": "--gateway", "ip_range": "--ip-range"} {
+			if value := config[key].(string); value != "" {
+				flags = append(flags, flag, shellQuote(value))
+			}
+		}
+	}
+
+	for key, flag := range map[string]string{"attachable": "--attachable", "internal": "--internal", "ingress": "--ingress"} {
+		if d.Get(key).(bool) {
+			flags = append(flags, flag)
+		}
+	}
+
+	if d.Get("encrypted").(bool) {
+		flags = append(flags, "--opt", encryptedOption)
+	}
+
+	for _, label := range mapKeyValues(d.Get("labels")) {
+		flags = append(flags, "--label", shellQuote(label))
+	}
+
+	flags = append(flags, shellQuote(d.Get("name").(string)))
+
+	stdout, err := runCmd(swarmManager, fmt.Sprintf(networkCreateCommand, strings.Join(flags, " ")))
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to create swarm network",
+			Detail:   fmt.Sprintf("Unable to create swarm network %s: %s", d.Get("name").(string), err),
+		})
+		return diags
+	}
+
+	data, err := io.ReadAll(stdout)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error reading stdout: %w", err))
+	}
+
+	d.SetId(strings.TrimSpace(string(data)))
+
+	return resourceNetworkRead(ctx, d, m)
+}
+
+func resourceNetworkRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	network, err := inspectNetwork(swarmManager, d.Id())
+	if err != nil {
+		if isNotFound(err) {
+			d.SetId("")
+			return diags
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to retrieve swarm network",
+			Detail:   fmt.Sprintf("Error inspecting swarm network %s: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	d.SetId(network.ID)
+
+	var ipamConfig []interface{}
+	for _, config := range network.IPAM.Config {
+		ipamConfig = append(ipamConfig, map[string]interface{}{
+			"subnet":   config.Subnet,
+			"gateway":  config.Gateway,
+			"ip_range": config.IPRange,
+		})
+	}
+
+	_, encrypted := network.Options[encryptedOption]
+
+	values := map[string]interface{}{
+		"name":        network.Name,
+		"driver":      network.Driver,
+		"ipam_config": ipamConfig,
+		"attachable":  network.Attachable,
+		"internal":    network.Internal,
+		"encrypted":   encrypted,
+		"ingress":     network.Ingress,
+		"labels":      network.Labels,
+		"scope":       network.Scope,
+	}
+
+	for key, value := range values {
+		if err := d.Set(key, value); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diags
+}
+
+func resourceNetworkDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	if _, err := runCmd(swarmManager, fmt.Sprintf(networkRemoveCommand, shellQuote(d.Id()))); err != nil && !isNotFound(err) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to remove swarm network",
+			Detail:   fmt.Sprintf("Unable to remove swarm network %s: %s", d.Get("name").(string), err),
+		})
+		return diags
+	}
+
+	d.SetId("")
+
+	return diags
+}
@@ -0,0 +1,363 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/aucloud/go-swarm"
+)
+
+// resourceNode manages the availability, role and labels of an existing
+// swarm node. The values the node had before it was managed are recorded so
+// they can be restored when the resource is destroyed. Only the configured
+// label keys are managed so that other labels are left alone.
+func resourceNode() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceNodeCreate,
+		ReadContext:   resourceNodeRead,
+		UpdateContext: resourceNodeUpdate,
+		DeleteContext: resourceNodeDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Schema: map[string]*schema.Schema{
+			"node": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"availability": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice([]string{"active", "pause", "drain"}, false),
+			},
+			"role": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice([]string{swarm.ManagerRole, swarm.WorkerRole}, false),
+			},
+			"labels": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"hostname": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"original_availability": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"original_role": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"original_labels": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// expandNodeLabels converts a labels map of the schema into node labels
+func expandNodeLabels(v interface{}) map[string]string {
+	labels := make(map[string]string)
+	for key, value := range v.(map[string]interface{}) {
+		labels[key] = value.(string)
+	}
+	return labels
+}
+
+// inspectNode returns the node with the given ID or hostname
+func inspectNode(swarmManager *swarm.Manager, id string) (Node, error) {
+	nodes, err := inspectNodes(swarmManager, shellQuote(id))
+	if err != nil {
+		return Node{}, err
+	}
+
+	if len(nodes) != 1 {
+		return Node{}, fmt.Errorf("error node %s not found", id)
+	}
+
+	return nodes[0], nil
+}
+
+// expandNodeSpec returns the desired spec of the node. Attributes that are
+// not configured are left unchanged and label keys that are no longer
+// configured are removed.
+func expandNodeSpec(d *schema.ResourceData, node Node) NodeSpec {
+	o, n := d.GetChange("labels")
+
+	return NodeSpec{
+		Availability: d.Get("availability").(string),
+		Role:         d.Get("role").(string),
+		Labels:       mergeLabels(node.Spec.Labels, expandNodeLabels(o), expandNodeLabels(n)),
+	}
+}
+
+// validateDemotion ensures that the given manager can be demoted without
+// the remaining managers losing raft quorum.
+func validateDemotion(nodes []Node, nodeID string) error {
+	managers, reachable := 0, 0
+	for _, node := range nodes {
+		if !node.IsManager() || node.ID == nodeID {
+			continue
+		}
+		managers++
+		if node.ManagerStatus != nil && node.ManagerStatus.Reachability == "reachable" {
+			reachable++
+		}
+	}
+
+	if managers == 0 {
+		return fmt.Errorf("error the last manager cannot be demoted")
+	}
+
+	if quorum := managers/2 + 1; reachable < quorum {
+		return fmt.Errorf(
+			"error only %d of the %d remaining managers are reachable, at least %d are required to keep quorum",
+			reachable, managers, quorum,
+		)
+	}
+
+	return nil
+}
+
+func resourceNodeCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	node, err := inspectNode(swarmManager, d.Get("node").(string))
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to find swarm node",
+			Detail:   fmt.Sprintf("Unable to find swarm node %s: %s", d.Get("node").(string), err),
+		})
+		return diags
+	}
+
+	if err := d.Set("original_availability", node.Spec.Availability); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("original_role", node.Spec.Role); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("original_labels", node.Spec.Labels); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(node.ID)
+
+	if err := updateNode(swarmManager, node.ID, node.Spec, expandNodeSpec(d, node)); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to update swarm node",
+			Detail:   fmt.Sprintf("Unable to update swarm node %s: %s", node.Description.Hostname, err),
+		})
+		return diags
+	}
+
+	return resourceNodeRead(ctx, d, m)
+}
+
+func resourceNodeRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	node, err := inspectNode(swarmManager, d.Id())
+	if err != nil {
+		if isNotFound(err) {
+			d.SetId("")
+			return diags
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to inspect swarm node",
+			Detail:   fmt.Sprintf("Error inspecting swarm node %s: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	// Imported nodes may be identified by hostname
+	if d.Get("node").(string) == "" {
+		if err := d.Set("node", d.Id()); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	d.SetId(node.ID)
+
+	// An imported node is restored to the values it had when imported
+	if d.Get("original_role").(string) == "" {
+		if err := d.Set("original_availability", node.Spec.Availability); err != nil {
+			return diag.FromErr(err)
+		}
+		if err := d.Set("original_role", node.Spec.Role); err != nil {
+			return diag.FromErr(err)
+		}
+		if err := d.Set("original_labels", node.Spec.Labels); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	if err := d.Set("hostname", node.Description.Hostname); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("availability", node.Spec.Availability); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("role", node.Spec.Role); err != nil {
+		return diag.FromErr(err)
+	}
+	// Only configured labels are reported, others may be managed by
+	// swarm_cluster or out of band
+	labels := make(map[string]string)
+	for key := range d.Get("labels").(map[string]interface{}) {
+		if value, ok := node.Spec.Labels[key]; ok {
+			labels[key] = value
+		}
+	}
+	if err := d.Set("labels", labels); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+func resourceNodeUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	node, err := inspectNode(swarmManager, d.Id())
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error inspecting swarm node %s: %w", d.Id(), err))
+	}
+
+	if err := updateNode(swarmManager, node.ID, node.Spec, expandNodeSpec(d, node)); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to update swarm node",
+			Detail:   fmt.Sprintf("Unable to update swarm node %s: %s", node.Description.Hostname, err),
+		})
+		return diags
+	}
+
+	return resourceNodeRead(ctx, d, m)
+}
+
+func resourceNodeDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	node, err := inspectNode(swarmManager, d.Id())
+	if err != nil {
+		if isNotFound(err) {
+			d.SetId("")
+			return diags
+		}
+		return diag.FromErr(fmt.Errorf("error inspecting swarm node %s: %w", d.Id(), err))
+	}
+
+	// Managed labels are restored to their original values or removed
+	managed := expandNodeLabels(d.Get("labels"))
+	originalLabels := expandNodeLabels(d.Get("original_labels"))
+	restored := make(map[string]string)
+	for key := range managed {
+		if value, ok := originalLabels[key]; ok {
+			restored[key] = value
+		}
+	}
+
+	original := NodeSpec{
+		Availability: d.Get("original_availability").(string),
+		Role:         d.Get("original_role").(string),
+		Labels:       mergeLabels(node.Spec.Labels, managed, restored),
+	}
+
+	if node.IsManager() && original.Role == swarm.WorkerRole {
+		nodes, err := getNodes(swarmManager)
+		if err == nil {
+			err = validateDemotion(nodes, node.ID)
+		}
+		if err != nil {
+			original.Role = ""
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Not demoting swarm node",
+				Detail: fmt.Sprintf(
+					"Swarm node %s was a worker before it was managed but is left a manager as it cannot be demoted safely: %s",
+					node.Description.Hostname, err,
+				),
+			})
+		}
+	}
+
+	if err := updateNode(swarmManager, node.ID, node.Spec, original); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to restore swarm node",
+			Detail:   fmt.Sprintf("Unable to restore the original values of swarm node %s: %s", node.Description.Hostname, err),
+		})
+		return diags
+	}
+
+	d.SetId("")
+
+	return diags
+}
@@ -0,0 +1,79 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"testing"
+
+	"github.com/aucloud/go-swarm"
+)
+
+func testManager(id, reachability string) Node {
+	return Node{
+		ID:            id,
+		Spec:          NodeSpec{Role: swarm.ManagerRole},
+		ManagerStatus: &ManagerStatus{Reachability: reachability},
+	}
+}
+
+func TestValidateDemotion(t *testing.T) {
+	worker := Node{ID: "worker1", Spec: NodeSpec{Role: swarm.WorkerRole}}
+
+	testCases := []struct {
+		name  string
+		nodes []Node
+		valid bool
+	}{
+		{
+			name:  "last manager",
+			nodes: []Node{testManager("manager1", "reachable"), worker},
+		},
+		{
+			name:  "one of two managers",
+			nodes: []Node{testManager("manager1", "reachable"), testManager("manager2", "reachable")},
+			valid: true,
+		},
+		{
+			name: "one of three managers",
+			nodes: []Node{
+				testManager("manager1", "reachable"), testManager("manager2", "reachable"), testManager("manager3", "reachable"),
+			},
+			valid: true,
+		},
+		{
+			name: "remaining managers unreachable",
+			nodes: []Node{
+				testManager("manager1", "reachable"), testManager("manager2", "reachable"), testManager("manager3", "unreachable"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateDemotion(tc.nodes, "manager1")
+			if tc.valid && err != nil {
+				t.Errorf("expected demotion to be valid got %s", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("expected demotion to be invalid")
+			}
+		})
+	}
+}
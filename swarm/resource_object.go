@@ -0,0 +1,266 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/aucloud/go-swarm"
+)
+
+const (
+	secretKind = "secret"
+	configKind = "config"
+
+	// hashSuffixLength is the number of hex digits of the data hash appended
+	// to the name of secrets and configs with append_hash set
+	hashSuffixLength = 8
+)
+
+func resourceSecret() *schema.Resource {
+	return resourceObject(secretKind)
+}
+
+func resourceConfig() *schema.Resource {
+	return resourceObject(configKind)
+}
+
+// resourceObject returns a resource managing swarm secrets or configs. Both
+// are immutable once created so every change replaces the object. Setting
+// append_hash suffixes the name with a hash of the data so that a new
+// version can be created before the old one is removed from services.
+// Swarm never returns the data of a secret so secrets cannot be imported.
+func resourceObject(kind string) *schema.Resource {
+	r := &schema.Resource{
+		CreateContext: func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+			return resourceObjectCreate(ctx, kind, d, m)
+		},
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+			return resourceObjectRead(ctx, kind, d, m)
+		},
+		DeleteContext: func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+			return resourceObjectDelete(ctx, kind, d, m)
+		},
+		CustomizeDiff: resourceObjectCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"data": {
+				Type:      schema.TypeString,
+				Required:  true,
+				ForceNew:  true,
+				Sensitive: kind == secretKind,
+			},
+			"labels": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				ForceNew: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"template_driver": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+			"append_hash": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  false,
+			},
+			"swarm_name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+
+	if kind == configKind {
+		r.Importer = &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		}
+	}
+
+	return r
+}
+
+// objectName returns the name of a secret or config in the swarm
+func objectName(name, data string, appendHash bool) string {
+	if !appendHash {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(data))
+	return fmt.Sprintf("%s-%s", name, hex.EncodeToString(sum[:])[:hashSuffixLength])
+}
+
+// resourceObjectCustomizeDiff computes the swarm_name at plan time so that
+// services referencing a new version of a secret or config can be planned.
+func resourceObjectCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown("name") || !d.NewValueKnown("data") {
+		return d.SetNewComputed("swarm_name")
+	}
+
+	name := objectName(d.Get("name").(string), d.Get("data").(string), d.Get("append_hash").(bool))
+	if name != d.Get("swarm_name").(string) {
+		return d.SetNew("swarm_name", name)
+	}
+
+	return nil
+}
+
+func resourceObjectCreate(ctx context.Context, kind string, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	data := d.Get("data").(string)
+	name := objectName(d.Get("name").(string), data, d.Get("append_hash").(bool))
+
+	var flags []string
+	for _, label := range mapKeyValues(d.Get("labels")) {
+		flags = append(flags, "--label", shellQuote(label))
+	}
+	if driver := d.Get("template_driver").(string); driver != "" {
+		flags = append(flags, "--template-driver", shellQuote(driver))
+	}
+	flags = append(flags, shellQuote(name))
+
+	cmd := fmt.Sprintf(objectCreateCommand, kind, strings.Join(flags, " "))
+	stdout, err := runCmdWithInput(swarmManager, cmd, []byte(data))
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Unable to create swarm %s", kind),
+			Detail:   fmt.Sprintf("Unable to create swarm %s %s: %s", kind, name, err),
+		})
+		return diags
+	}
+
+	id, err := io.ReadAll(stdout)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error reading stdout: %w", err))
+	}
+
+	d.SetId(strings.TrimSpace(string(id)))
+
+	return resourceObjectRead(ctx, kind, d, m)
+}
+
+func resourceObjectRead(ctx context.Context, kind string, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	object, err := inspectObject(swarmManager, kind, d.Id())
+	if err != nil {
+		if isNotFound(err) {
+			d.SetId("")
+			return diags
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Unable to retrieve swarm %s", kind),
+			Detail:   fmt.Sprintf("Error inspecting swarm %s %s: %s", kind, d.Id(), err),
+		})
+		return diags
+	}
+
+	d.SetId(object.ID)
+
+	// Imported objects are known by their name in the swarm
+	if d.Get("name").(string) == "" {
+		if err := d.Set("name", object.Spec.Name); err != nil {
+			return diag.FromErr(err)
+		}
+		if err := d.Set("append_hash", false); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// The data of secrets is never returned by the swarm
+	if kind == configKind {
+		if err := d.Set("data", string(object.Spec.Data)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	driver := ""
+	if object.Spec.Templating != nil {
+		driver = object.Spec.Templating.Name
+	}
+
+	if err := d.Set("swarm_name", object.Spec.Name); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("labels", object.Spec.Labels); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("template_driver", driver); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+func resourceObjectDelete(ctx context.Context, kind string, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	if _, err := runCmd(swarmManager, fmt.Sprintf(objectRemoveCommand, kind, shellQuote(d.Id()))); err != nil && !isNotFound(err) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Unable to remove swarm %s", kind),
+			Detail:   fmt.Sprintf("Unable to remove swarm %s %s: %s", kind, d.Get("swarm_name").(string), err),
+		})
+		return diags
+	}
+
+	d.SetId("")
+
+	return diags
+}
@@ -0,0 +1,984 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/aucloud/go-swarm"
+)
+
+const (
+	serviceModeReplicated = "replicated"
+	serviceModeGlobal     = "global"
+)
+
+func resourceService() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceServiceCreate,
+		ReadContext:   resourceServiceRead,
+		UpdateContext: resourceServiceUpdate,
+		DeleteContext: resourceServiceDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"image": {
+				Type:             schema.TypeString,
+				Required:         true,
+				DiffSuppressFunc: suppressImageDigest,
+			},
+			"args": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"mode": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      serviceModeReplicated,
+				ValidateFunc: validation.StringInSlice([]string{serviceModeReplicated, serviceModeGlobal}, false),
+			},
+			"replicas": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Default:  1,
+				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+					return d.Get("mode").(string) == serviceModeGlobal
+				},
+			},
+			"env": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"labels": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"mounts": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"type": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "volume",
+							ValidateFunc: validation.StringInSlice([]string{"volume", "bind", "tmpfs"}, false),
+						},
+						"source": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"target": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"read_only": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  false,
+						},
+					},
+				},
+			},
+			"secrets": resourceServiceFileReference("secret_name"),
+			"configs": resourceServiceFileReference("config_name"),
+			"networks": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"ports": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"target_port": {
+							Type:     schema.TypeInt,
+							Required: true,
+						},
+						"published_port": {
+							Type:     schema.TypeInt,
+							Optional: true,
+							Computed: true,
+						},
+						"protocol": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "tcp",
+							ValidateFunc: validation.StringInSlice([]string{"tcp", "udp", "sctp"}, false),
+						},
+						"publish_mode": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "ingress",
+							ValidateFunc: validation.StringInSlice([]string{"ingress", "host"}, false),
+						},
+					},
+				},
+			},
+			"constraints": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"placement_preferences": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"resources": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"limit_cpus": {
+							Type:     schema.TypeFloat,
+							Optional: true,
+						},
+						"limit_memory": {
+							Type:     schema.TypeInt,
+							Optional: true,
+						},
+						"reserve_cpus": {
+							Type:     schema.TypeFloat,
+							Optional: true,
+						},
+						"reserve_memory": {
+							Type:     schema.TypeInt,
+							Optional: true,
+						},
+					},
+				},
+			},
+			"update_config":   resourceServiceUpdateConfig(),
+			"rollback_config": resourceServiceUpdateConfig(),
+			"healthcheck": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"command": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"interval": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							DiffSuppressFunc: suppressEquivalentDurations,
+						},
+						"timeout": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							DiffSuppressFunc: suppressEquivalentDurations,
+						},
+						"start_period": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							DiffSuppressFunc: suppressEquivalentDurations,
+						},
+						"retries": {
+							Type:     schema.TypeInt,
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// resourceServiceFileReference is the schema of the `secrets` and `configs`
+// blocks of a swarm_service where nameKey references the swarm object.
+func resourceServiceFileReference(nameKey string) *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				nameKey: {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"file_name": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"uid": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "0",
+				},
+				"gid": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "0",
+				},
+				"mode": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "0444",
+				},
+			},
+		},
+	}
+}
+
+// resourceServiceUpdateConfig is the schema of the `update_config` and
+// `rollback_config` blocks of a swarm_service.
+func resourceServiceUpdateConfig() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Computed: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"parallelism": {
+					Type:     schema.TypeInt,
+					Optional: true,
+					Computed: true,
+				},
+				"delay": {
+					Type:             schema.TypeString,
+					Optional:         true,
+					Computed:         true,
+					DiffSuppressFunc: suppressEquivalentDurations,
+				},
+				"failure_action": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Computed:     true,
+					ValidateFunc: validation.StringInSlice([]string{"pause", "continue", "rollback"}, false),
+				},
+				"monitor": {
+					Type:             schema.TypeString,
+					Optional:         true,
+					Computed:         true,
+					DiffSuppressFunc: suppressEquivalentDurations,
+				},
+				"max_failure_ratio": {
+					Type:     schema.TypeFloat,
+					Optional: true,
+					Computed: true,
+				},
+				"order": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Computed:     true,
+					ValidateFunc: validation.StringInSlice([]string{"stop-first", "start-first"}, false),
+				},
+			},
+		},
+	}
+}
+
+// suppressImageDigest suppresses the diff between an image and the same
+// image pinned to the digest it was resolved to by the swarm, e.g. `nginx`
+// and `nginx:latest@sha256:...`.
+func suppressImageDigest(k, old, new string, d *schema.ResourceData) bool {
+	if old == new {
+		return true
+	}
+
+	oldImage, _ := splitImageDigest(old)
+	newImage, newDigest := splitImageDigest(new)
+
+	// An image pinned in the configuration must match exactly
+	if newDigest != "" {
+		return normaliseImage(oldImage) == normaliseImage(newImage) && strings.HasSuffix(old, "@"+newDigest)
+	}
+
+	return normaliseImage(oldImage) == normaliseImage(newImage)
+}
+
+// splitImageDigest splits an image reference into the image and its digest
+func splitImageDigest(image string) (string, string) {
+	if i := strings.Index(image, "@"); i != -1 {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
+
+// normaliseImage returns the image as the swarm reports it for comparison,
+// without the default docker.io registry and with the implicit latest tag.
+func normaliseImage(image string) string {
+	image = strings.TrimPrefix(image, "docker.io/library/")
+	image = strings.TrimPrefix(image, "docker.io/")
+
+	// A colon before the last slash separates a registry host from its port
+	if !strings.Contains(image[strings.LastIndex(image, "/")+1:], ":") {
+		image += ":latest"
+	}
+
+	return image
+}
+
+// suppressEquivalentDurations suppresses the diff between two durations
+// written differently such as `1m` and `60s`.
+func suppressEquivalentDurations(k, old, new string, d *schema.ResourceData) bool {
+	o, err := time.ParseDuration(old)
+	if err != nil {
+		return false
+	}
+	n, err := time.ParseDuration(new)
+	if err != nil {
+		return false
+	}
+	return o == n
+}
+
+// formatDuration formats a duration in nanoseconds as reported by the swarm
+func formatDuration(ns int64) string {
+	if ns == 0 {
+		return ""
+	}
+	return time.Duration(ns).String()
+}
+
+// serviceListFlag describes how a list, set or map attribute of a service
+// is passed to `docker service create` and `docker service update`.
+type serviceListFlag struct {
+	key  string
+	flag string
+
+	// add returns the flag values that add the attribute's elements
+	add func(interface{}) []string
+	// rm returns the flag values that remove the attribute's elements in
+	// the same order as add, i.e. rm(v)[i] removes the element add(v)[i]
+	rm func(interface{}) []string
+
+	// rmFirst is true if `docker service update` applies the removals
+	// before the additions. Otherwise an element re-added with the same
+	// key would be removed again.
+	rmFirst bool
+}
+
+// changes returns the flag values that remove and add the elements of the
+// attribute to update it from o to n. Unchanged elements are left alone.
+func (f serviceListFlag) changes(o, n interface{}) (rm []string, add []string) {
+	oldValues, oldKeys := f.add(o), f.rm(o)
+	newValues, newKeys := f.add(n), f.rm(n)
+
+	oldSet := make(map[string]bool)
+	for _, value := range oldValues {
+		oldSet[value] = true
+	}
+
+	newSet := make(map[string]bool)
+	for _, value := range newValues {
+		newSet[value] = true
+	}
+
+	newKeySet := make(map[string]bool)
+	for _, key := range newKeys {
+		newKeySet[key] = true
+	}
+
+	for i, key := range oldKeys {
+		if newSet[oldValues[i]] {
+			continue
+		}
+		// Changed elements are replaced by adding them again
+		if !f.rmFirst && newKeySet[key] {
+			continue
+		}
+		rm = append(rm, key)
+	}
+
+	for _, value := range newValues {
+		if !oldSet[value] {
+			add = append(add, value)
+		}
+	}
+
+	return rm, add
+}
+
+func mapKeyValues(v interface{}) []string {
+	var values []string
+	for _, k := range mapKeys(v) {
+		values = append(values, fmt.Sprintf("%s=%s", k, v.(map[string]interface{})[k]))
+	}
+	return values
+}
+
+func mapKeys(v interface{}) []string {
+	var keys []string
+	for k := range v.(map[string]interface{}) {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func setStrings(v interface{}) []string {
+	var values []string
+	for _, v := range v.(*schema.Set).List() {
+		values = append(values, v.(string))
+	}
+	sort.Strings(values)
+	return values
+}
+
+func listStrings(v interface{}) []string {
+	var values []string
+	for _, v := range v.([]interface{}) {
+		values = append(values, v.(string))
+	}
+	return values
+}
+
+// fileReferenceFlags returns the add and rm functions of the `secrets` or
+// `configs` attributes where nameKey references the swarm object.
+func fileReferenceFlags(nameKey string) (func(interface{}) []string, func(interface{}) []string) {
+	add := func(v interface{}) []string {
+		var values []string
+		for _, ref := range v.([]interface{}) {
+			ref := ref.(map[string]interface{})
+			values = append(values, fmt.Sprintf(
+				"source=%s,target=%s,uid=%s,gid=%s,mode=%s",
+				ref[nameKey], ref["file_name"], ref["uid"], ref["gid"], ref["mode"],
+			))
+		}
+		return values
+	}
+	rm := func(v interface{}) []string {
+		var values []string
+		for _, ref := range v.([]interface{}) {
+			values = append(values, ref.(map[string]interface{})[nameKey].(string))
+		}
+		return values
+	}
+	return add, rm
+}
+
+var (
+	secretFlagsAdd, secretFlagsRm = fileReferenceFlags("secret_name")
+	configFlagsAdd, configFlagsRm = fileReferenceFlags("config_name")
+)
+
+var serviceListFlags = []serviceListFlag{
+	{key: "secrets", flag: "--secret", add: secretFlagsAdd, rm: secretFlagsRm, rmFirst: true},
+	{key: "configs", flag: "--config", add: configFlagsAdd, rm: configFlagsRm, rmFirst: true},
+	{key: "env", flag: "--env", add: mapKeyValues, rm: mapKeys},
+	{key: "labels", flag: "--label", add: mapKeyValues, rm: mapKeys},
+	{key: "networks", flag: "--network", add: setStrings, rm: setStrings, rmFirst: true},
+	{key: "constraints", flag: "--constraint", add: setStrings, rm: setStrings},
+	{key: "placement_preferences", flag: "--placement-pref", add: listStrings, rm: listStrings, rmFirst: true},
+	{
+		key:  "mounts",
+		flag: "--mount",
+		add: func(v interface{}) []string {
+			var values []string
+			for _, mount := range v.([]interface{}) {
+				mount := mount.(map[string]interface{})
+				value := fmt.Sprintf("type=%s,target=%s", mount["type"], mount["target"])
+				if source := mount["source"].(string); source != "" {
+					value += fmt.Sprintf(",source=%s", source)
+				}
+				if mount["read_only"].(bool) {
+					value += ",readonly"
+				}
+				values = append(values, value)
+			}
+			return values
+		},
+		rm: func(v interface{}) []string {
+			var values []string
+			for _, mount := range v.([]interface{}) {
+				values = append(values, mount.(map[string]interface{})["target"].(string))
+			}
+			return values
+		},
+	},
+	{
+		key:     "ports",
+		flag:    "--publish",
+		rmFirst: true,
+		add: func(v interface{}) []string {
+			var values []string
+			for _, port := range v.([]interface{}) {
+				port := port.(map[string]interface{})
+				value := fmt.Sprintf(
+					"mode=%s,protocol=%s,target=%d",
+					port["publish_mode"], port["protocol"], port["target_port"],
+				)
+				if published := port["published_port"].(int); published != 0 {
+					value += fmt.Sprintf(",published=%d", published)
+				}
+				values = append(values, value)
+			}
+			return values
+		},
+		// A bare target port is read by docker as a tcp ingress port
+		rm: func(v interface{}) []string {
+			var values []string
+			for _, port := range v.([]interface{}) {
+				port := port.(map[string]interface{})
+				values = append(values, fmt.Sprintf(
+					"mode=%s,protocol=%s,target=%d",
+					port["publish_mode"], port["protocol"], port["target_port"],
+				))
+			}
+			return values
+		},
+	},
+}
+
+// serviceFlags returns the flags for `docker service create` or, if update
+// is true, the flags for `docker service update` that apply the changes.
+func serviceFlags(d *schema.ResourceData, update bool) []string {
+	var flags []string
+
+	if !update {
+		flags = append(flags,
+			"--name", shellQuote(d.Get("name").(string)),
+			"--mode", d.Get("mode").(string),
+		)
+	}
+
+	if d.Get("mode").(string) == serviceModeReplicated && (!update || d.HasChange("replicas")) {
+		flags = append(flags, "--replicas", fmt.Sprint(d.Get("replicas").(int)))
+	}
+
+	if update && d.HasChange("image") {
+		flags = append(flags, "--image", shellQuote(d.Get("image").(string)))
+	}
+
+	if update && d.HasChange("args") {
+		var args []string
+		for _, arg := range listStrings(d.Get("args")) {
+			args = append(args, shellQuote(arg))
+		}
+		flags = append(flags, "--args", shellQuote(strings.Join(args, " ")))
+	}
+
+	for _, f := range serviceListFlags {
+		if !update {
+			for _, value := range f.add(d.Get(f.key)) {
+				flags = append(flags, f.flag, shellQuote(value))
+			}
+			continue
+		}
+
+		if !d.HasChange(f.key) {
+			continue
+		}
+
+		rm, add := f.changes(d.GetChange(f.key))
+		for _, value := range rm {
+			flags = append(flags, f.flag+"-rm", shellQuote(value))
+		}
+		for _, value := range add {
+			flags = append(flags, f.flag+"-add", shellQuote(value))
+		}
+	}
+
+	if !update || d.HasChange("resources") {
+		resources := map[string]interface{}{
+			"limit_cpus":     0.0,
+			"limit_memory":   0,
+			"reserve_cpus":   0.0,
+			"reserve_memory": 0,
+		}
+		if v := d.Get("resources").([]interface{}); len(v) > 0 && v[0] != nil {
+			resources = v[0].(map[string]interface{})
+		}
+
+		for key, flag := range map[string]string{"limit_cpus": "--limit-cpu", "reserve_cpus": "--reserve-cpu"} {
+			if cpus := resources[key].(float64); update || cpus != 0 {
+				flags = append(flags, flag, fmt.Sprintf("%g", cpus))
+			}
+		}
+		for key, flag := range map[string]string{"limit_memory": "--limit-memory", "reserve_memory": "--reserve-memory"} {
+			if memory := resources[key].(int); update || memory != 0 {
+				flags = append(flags, flag, fmt.Sprint(memory))
+			}
+		}
+	}
+
+	for key, prefix := range map[string]string{"update_config": "--update-", "rollback_config": "--rollback-"} {
+		if update && !d.HasChange(key) {
+			continue
+		}
+
+		v := d.Get(key).([]interface{})
+		if len(v) == 0 || v[0] == nil {
+			continue
+		}
+		config := v[0].(map[string]interface{})
+
+		if parallelism := config["parallelism"].(int); parallelism != 0 {
+			flags = append(flags, prefix+"parallelism", fmt.Sprint(parallelism))
+		}
+		if ratio := config["max_failure_ratio"].(float64); ratio != 0 {
+			flags = append(flags, prefix+"max-failure-ratio", fmt.Sprintf("%g", ratio))
+		}
+		for _, field := range []string{"delay", "failure_action", "monitor", "order"} {
+			if value := config[field].(string); value != "" {
+				flags = append(flags, prefix+strings.ReplaceAll(field, "_", "-"), shellQuote(value))
+			}
+		}
+	}
+
+	if !update || d.HasChange("healthcheck") {
+		v := d.Get("healthcheck").([]interface{})
+		if len(v) > 0 && v[0] != nil {
+			healthcheck := v[0].(map[string]interface{})
+
+			flags = append(flags, "--health-cmd", shellQuote(healthcheck["command"].(string)))
+			for _, field := range []string{"interval", "timeout", "start_period"} {
+				if value := healthcheck[field].(string); value != "" {
+					flags = append(flags, "--health-"+strings.ReplaceAll(field, "_", "-"), shellQuote(value))
+				}
+			}
+			if retries := healthcheck["retries"].(int); retries != 0 {
+				flags = append(flags, "--health-retries", fmt.Sprint(retries))
+			}
+		} else if update {
+			flags = append(flags, "--no-healthcheck")
+		}
+	}
+
+	if !update {
+		flags = append(flags, shellQuote(d.Get("image").(string)))
+		for _, arg := range listStrings(d.Get("args")) {
+			flags = append(flags, shellQuote(arg))
+		}
+	}
+
+	return flags
+}
+
+func resourceServiceCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	cmd := fmt.Sprintf(serviceCreateCommand, strings.Join(serviceFlags(d, false), " "))
+	stdout, err := runCmd(swarmManager, cmd)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to create swarm service",
+			Detail:   fmt.Sprintf("Unable to create swarm service %s: %s", d.Get("name").(string), err),
+		})
+		return diags
+	}
+
+	data, err := io.ReadAll(stdout)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error reading stdout: %w", err))
+	}
+
+	d.SetId(strings.TrimSpace(string(data)))
+
+	return resourceServiceRead(ctx, d, m)
+}
+
+func resourceServiceRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	service, err := inspectService(swarmManager, d.Id())
+	if err != nil {
+		if isNotFound(err) {
+			d.SetId("")
+			return diags
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to retrieve swarm service",
+			Detail:   fmt.Sprintf("Error inspecting swarm service %s: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	spec := service.Spec
+	container := spec.TaskTemplate.ContainerSpec
+
+	d.SetId(service.ID)
+
+	if err := d.Set("name", spec.Name); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("image", container.Image); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("args", container.Args); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("labels", spec.Labels); err != nil {
+		return diag.FromErr(err)
+	}
+
+	env := make(map[string]string)
+	for _, e := range container.Env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 2 {
+			env[kv[0]] = kv[1]
+		} else {
+			env[kv[0]] = ""
+		}
+	}
+	if err := d.Set("env", env); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if spec.Mode.Global != nil {
+		if err := d.Set("mode", serviceModeGlobal); err != nil {
+			return diag.FromErr(err)
+		}
+	} else if spec.Mode.Replicated != nil {
+		if err := d.Set("mode", serviceModeReplicated); err != nil {
+			return diag.FromErr(err)
+		}
+		if err := d.Set("replicas", spec.Mode.Replicated.Replicas); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	var mounts []interface{}
+	for _, mount := range container.Mounts {
+		mounts = append(mounts, map[string]interface{}{
+			"type":      mount.Type,
+			"source":    mount.Source,
+			"target":    mount.Target,
+			"read_only": mount.ReadOnly,
+		})
+	}
+	if err := d.Set("mounts", mounts); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("secrets", flattenFileReferences(container.Secrets, "secret_name")); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("configs", flattenFileReferences(container.Configs, "config_name")); err != nil {
+		return diag.FromErr(err)
+	}
+
+	var networks []string
+	for _, network := range spec.TaskTemplate.Networks {
+		name, err := getNetworkName(swarmManager, network.Target)
+		if err != nil {
+			return diag.FromErr(fmt.Errorf("error getting network name of %s: %w", network.Target, err))
+		}
+		networks = append(networks, name)
+	}
+	if err := d.Set("networks", networks); err != nil {
+		return diag.FromErr(err)
+	}
+
+	var ports []interface{}
+	if spec.EndpointSpec != nil {
+		for _, port := range spec.EndpointSpec.Ports {
+			ports = append(ports, map[string]interface{}{
+				"target_port":    port.TargetPort,
+				"published_port": port.PublishedPort,
+				"protocol":       port.Protocol,
+				"publish_mode":   port.PublishMode,
+			})
+		}
+	}
+	if err := d.Set("ports", ports); err != nil {
+		return diag.FromErr(err)
+	}
+
+	var constraints, preferences []string
+	if placement := spec.TaskTemplate.Placement; placement != nil {
+		constraints = placement.Constraints
+		for _, preference := range placement.Preferences {
+			preferences = append(preferences, "spread="+preference.Spread.SpreadDescriptor)
+		}
+	}
+	if err := d.Set("constraints", constraints); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("placement_preferences", preferences); err != nil {
+		return diag.FromErr(err)
+	}
+
+	var resources []interface{}
+	if r := spec.TaskTemplate.Resources; r != nil && (r.Limits != nil || r.Reservations != nil) {
+		limits, reservations := Resources{}, Resources{}
+		if r.Limits != nil {
+			limits = *r.Limits
+		}
+		if r.Reservations != nil {
+			reservations = *r.Reservations
+		}
+		resources = append(resources, map[string]interface{}{
+			"limit_cpus":     float64(limits.NanoCPUs) / 1e9,
+			"limit_memory":   limits.MemoryBytes,
+			"reserve_cpus":   float64(reservations.NanoCPUs) / 1e9,
+			"reserve_memory": reservations.MemoryBytes,
+		})
+	}
+	if err := d.Set("resources", resources); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("update_config", flattenUpdateConfig(spec.UpdateConfig)); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("rollback_config", flattenUpdateConfig(spec.RollbackConfig)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	var healthcheck []interface{}
+	if h := container.Healthcheck; h != nil && len(h.Test) == 2 && h.Test[0] == "CMD-SHELL" {
+		healthcheck = append(healthcheck, map[string]interface{}{
+			"command":      h.Test[1],
+			"interval":     formatDuration(h.Interval),
+			"timeout":      formatDuration(h.Timeout),
+			"start_period": formatDuration(h.StartPeriod),
+			"retries":      h.Retries,
+		})
+	}
+	if err := d.Set("healthcheck", healthcheck); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+// flattenFileReferences converts the secrets or configs of a service into
+// the `secrets` or `configs` block of a swarm_service.
+func flattenFileReferences(refs []FileReference, nameKey string) []interface{} {
+	var result []interface{}
+
+	for _, ref := range refs {
+		name := ref.SecretName
+		if name == "" {
+			name = ref.ConfigName
+		}
+		result = append(result, map[string]interface{}{
+			nameKey:     name,
+			"file_name": ref.File.Name,
+			"uid":       ref.File.UID,
+			"gid":       ref.File.GID,
+			"mode":      fmt.Sprintf("%04o", ref.File.Mode),
+		})
+	}
+
+	return result
+}
+
+// flattenUpdateConfig converts the update or rollback config of a service
+// into the `update_config` or `rollback_config` block of a swarm_service.
+func flattenUpdateConfig(config *UpdateConfig) []interface{} {
+	if config == nil {
+		return nil
+	}
+
+	return []interface{}{
+		map[string]interface{}{
+			"parallelism":       config.Parallelism,
+			"delay":             time.Duration(config.Delay).String(),
+			"failure_action":    config.FailureAction,
+			"monitor":           time.Duration(config.Monitor).String(),
+			"max_failure_ratio": config.MaxFailureRatio,
+			"order":             config.Order,
+		},
+	}
+}
+
+func resourceServiceUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	flags := serviceFlags(d, true)
+	if len(flags) > 0 {
+		cmd := fmt.Sprintf(serviceUpdateCommand, strings.Join(flags, " "), shellQuote(d.Id()))
+		if _, err := runCmd(swarmManager, cmd); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Unable to update swarm service",
+				Detail:   fmt.Sprintf("Unable to update swarm service %s: %s", d.Get("name").(string), err),
+			})
+			return diags
+		}
+	}
+
+	return resourceServiceRead(ctx, d, m)
+}
+
+func resourceServiceDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	if _, err := runCmd(swarmManager, fmt.Sprintf(serviceRemoveCommand, shellQuote(d.Id()))); err != nil && !isNotFound(err) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to remove swarm service",
+			Detail:   fmt.Sprintf("Unable to remove swarm service %s: %s", d.Get("name").(string), err),
+		})
+		return diags
+	}
+
+	d.SetId("")
+
+	return diags
+}
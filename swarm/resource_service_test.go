@@ -0,0 +1,158 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestServiceListFlagChanges(t *testing.T) {
+	flags := make(map[string]serviceListFlag)
+	for _, f := range serviceListFlags {
+		flags[f.key] = f
+	}
+
+	mount := func(target, source string) map[string]interface{} {
+		return map[string]interface{}{"type": "volume", "target": target, "source": source, "read_only": false}
+	}
+	port := func(target, published int, protocol, mode string) map[string]interface{} {
+		return map[string]interface{}{"target_port": target, "published_port": published, "protocol": protocol, "publish_mode": mode}
+	}
+	secret := func(name, fileName string) map[string]interface{} {
+		return map[string]interface{}{"secret_name": name, "file_name": fileName, "uid": "0", "gid": "0", "mode": "0444"}
+	}
+
+	testCases := []struct {
+		name string
+		key  string
+		o, n interface{}
+		rm   []string
+		add  []string
+	}{
+		{
+			name: "env value changed",
+			key:  "env",
+			o:    map[string]interface{}{"A": "1", "B": "2", "C": "3"},
+			n:    map[string]interface{}{"A": "1", "B": "4"},
+			rm:   []string{"C"},
+			add:  []string{"B=4"},
+		},
+		{
+			name: "labels added",
+			key:  "labels",
+			o:    map[string]interface{}{"a": "1"},
+			n:    map[string]interface{}{"a": "1", "a.b": "2"},
+			add:  []string{"a.b=2"},
+		},
+		{
+			name: "constraints replaced",
+			key:  "constraints",
+			o:    schema.NewSet(schema.HashString, []interface{}{"node.role==manager", "node.labels.zone==a"}),
+			n:    schema.NewSet(schema.HashString, []interface{}{"node.role==manager", "node.labels.zone==b"}),
+			rm:   []string{"node.labels.zone==a"},
+			add:  []string{"node.labels.zone==b"},
+		},
+		{
+			name: "mount source changed",
+			key:  "mounts",
+			o:    []interface{}{mount("/data", "data"), mount("/logs", "logs")},
+			n:    []interface{}{mount("/data", "data2"), mount("/logs", "logs")},
+			add:  []string{"type=volume,target=/data,source=data2"},
+		},
+		{
+			name: "mount removed",
+			key:  "mounts",
+			o:    []interface{}{mount("/data", "data"), mount("/logs", "logs")},
+			n:    []interface{}{mount("/logs", "logs")},
+			rm:   []string{"/data"},
+		},
+		{
+			name: "secret target changed",
+			key:  "secrets",
+			o:    []interface{}{secret("db", "db"), secret("tls", "tls")},
+			n:    []interface{}{secret("db", "password"), secret("tls", "tls")},
+			rm:   []string{"db"},
+			add:  []string{"source=db,target=password,uid=0,gid=0,mode=0444"},
+		},
+		{
+			name: "udp port removed",
+			key:  "ports",
+			o:    []interface{}{port(80, 8080, "tcp", "ingress"), port(53, 53, "udp", "ingress")},
+			n:    []interface{}{port(80, 8080, "tcp", "ingress")},
+			rm:   []string{"mode=ingress,protocol=udp,target=53"},
+		},
+		{
+			name: "host mode port republished",
+			key:  "ports",
+			o:    []interface{}{port(80, 80, "tcp", "host")},
+			n:    []interface{}{port(80, 8080, "tcp", "host")},
+			rm:   []string{"mode=host,protocol=tcp,target=80"},
+			add:  []string{"mode=host,protocol=tcp,target=80,published=8080"},
+		},
+		{
+			name: "networks unchanged",
+			key:  "networks",
+			o:    schema.NewSet(schema.HashString, []interface{}{"backend"}),
+			n:    schema.NewSet(schema.HashString, []interface{}{"backend"}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rm, add := flags[tc.key].changes(tc.o, tc.n)
+			if !reflect.DeepEqual(rm, tc.rm) {
+				t.Errorf("expected rm %v got %v", tc.rm, rm)
+			}
+			if !reflect.DeepEqual(add, tc.add) {
+				t.Errorf("expected add %v got %v", tc.add, add)
+			}
+		})
+	}
+}
+
+func TestSuppressImageDigest(t *testing.T) {
+	const digest = "sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac"
+
+	testCases := []struct {
+		old, new string
+		suppress bool
+	}{
+		{"nginx:1.21@" + digest, "nginx:1.21", true},
+		{"nginx:latest@" + digest, "nginx", true},
+		{"docker.io/library/nginx:latest@" + digest, "nginx", true},
+		{"nginx:latest@" + digest, "docker.io/library/nginx:latest", true},
+		{"example/app:latest@" + digest, "docker.io/example/app", true},
+		{"localhost:5000/app:latest@" + digest, "localhost:5000/app", true},
+		{"nginx:latest@" + digest, "nginx@" + digest, true},
+		{"nginx:1.21@" + digest, "nginx", false},
+		{"nginx:1.21@" + digest, "nginx:1.22", false},
+		{"nginx:latest@" + digest, "nginx@sha256:0000", false},
+		{"localhost:5000/app:latest@" + digest, "localhost:5001/app", false},
+	}
+
+	for _, tc := range testCases {
+		if actual := suppressImageDigest("image", tc.old, tc.new, nil); actual != tc.suppress {
+			t.Errorf("expected suppressing %q for %q to be %t", tc.old, tc.new, tc.suppress)
+		}
+	}
+}
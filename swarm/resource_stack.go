@@ -0,0 +1,436 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"gopkg.in/yaml.v2"
+
+	"github.com/aucloud/go-swarm"
+)
+
+// composeFile is the subset of a Compose file needed to know which services
+// a stack deploys.
+type composeFile struct {
+	Services map[string]struct {
+		Image string `yaml:"image"`
+	} `yaml:"services"`
+}
+
+func resourceStack() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceStackCreate,
+		ReadContext:   resourceStackRead,
+		UpdateContext: resourceStackUpdate,
+		DeleteContext: resourceStackDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		CustomizeDiff: resourceStackCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"compose": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"compose", "compose_file"},
+			},
+			"compose_file": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"compose", "compose_file"},
+			},
+			"environment": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"prune": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"with_registry_auth": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"compose_sha256": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"services": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+// getCompose returns the Compose document of a stack either given inline or
+// read from compose_file.
+func getCompose(d interface{ Get(string) interface{} }) (string, error) {
+	if compose := d.Get("compose").(string); compose != "" {
+		return compose, nil
+	}
+
+	path := d.Get("compose_file").(string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading compose file %s: %w", path, err)
+	}
+
+	return string(data), nil
+}
+
+// interpolationPattern matches the variables of a Compose document like
+// the template package of the docker CLI: `$$`, `$VAR` and `${VAR}` with an
+// optional `:-`, `-`, `:?`, `?`, `:+` or `+` modifier. Any other `${` is
+// invalid and a `$` followed by anything else is left as is.
+var interpolationPattern = regexp.MustCompile(
+	`\$(?:(?P<escaped>\$)|(?P<named>[_a-zA-Z][_a-zA-Z0-9]*)|\{(?:(?P<braced>[_a-zA-Z][_a-zA-Z0-9]*)(?P<modifier>:?[-?+])?(?P<argument>[^}]*)\}|(?P<invalid>)))`,
+)
+
+// interpolate substitutes the variables in a Compose document with the
+// given environment the same way `docker stack deploy` does. If escape is
+// true every literal `$` in the result is escaped as `$$` so that rendering
+// it again yields the same document.
+func interpolate(compose string, environment map[string]interface{}, escape bool) (string, error) {
+	lookup := func(name string) (string, bool) {
+		value, ok := environment[name]
+		if !ok {
+			return "", false
+		}
+		return value.(string), true
+	}
+
+	literal := func(s string) string {
+		if escape {
+			return strings.ReplaceAll(s, "$", "$$")
+		}
+		return s
+	}
+
+	var (
+		b    strings.Builder
+		last int
+	)
+
+	for _, match := range interpolationPattern.FindAllStringSubmatchIndex(compose, -1) {
+		group := func(name string) (string, bool) {
+			i := interpolationPattern.SubexpIndex(name)
+			if match[2*i] < 0 {
+				return "", false
+			}
+			return compose[match[2*i]:match[2*i+1]], true
+		}
+
+		b.WriteString(literal(compose[last:match[0]]))
+		last = match[1]
+
+		if _, ok := group("escaped"); ok {
+			b.WriteString(literal("$"))
+			continue
+		}
+
+		if name, ok := group("named"); ok {
+			value, _ := lookup(name)
+			b.WriteString(literal(value))
+			continue
+		}
+
+		name, ok := group("braced")
+		if !ok {
+			return "", fmt.Errorf("error invalid interpolation format in %q", compose[match[0]:])
+		}
+
+		modifier, _ := group("modifier")
+		argument, _ := group("argument")
+		if modifier == "" && argument != "" {
+			return "", fmt.Errorf("error invalid interpolation format in %q", compose[match[0]:match[1]])
+		}
+
+		value, set := lookup(name)
+
+		// With a colon an empty variable is treated as unset
+		if strings.HasPrefix(modifier, ":") && value == "" {
+			set = false
+		}
+
+		switch strings.TrimPrefix(modifier, ":") {
+		case "-":
+			if !set {
+				value = argument
+			}
+		case "?":
+			if !set {
+				return "", fmt.Errorf("error required variable %s is missing a value: %s", name, argument)
+			}
+		case "+":
+			if set {
+				value = argument
+			} else {
+				value = ""
+			}
+		}
+
+		b.WriteString(literal(value))
+	}
+
+	b.WriteString(literal(compose[last:]))
+
+	return b.String(), nil
+}
+
+// normalizeImage strips the digest from an image reference and adds the
+// implicit `latest` tag so that images can be compared.
+func normalizeImage(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+
+	if !strings.Contains(image[strings.LastIndex(image, "/")+1:], ":") {
+		image += ":latest"
+	}
+
+	return image
+}
+
+// composeServices returns the fully qualified names and images of the
+// services defined in the given Compose document.
+func composeServices(stack, compose string, environment map[string]interface{}) (map[string]interface{}, error) {
+	var file composeFile
+
+	compose, err := interpolate(compose, environment, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal([]byte(compose), &file); err != nil {
+		return nil, fmt.Errorf("error parsing compose file: %w", err)
+	}
+
+	services := make(map[string]interface{})
+	for name, service := range file.Services {
+		services[fmt.Sprintf("%s_%s", stack, name)] = normalizeImage(service.Image)
+	}
+
+	return services, nil
+}
+
+// resourceStackCustomizeDiff compares the services of the Compose document
+// with the services actually deployed so that a stack is deployed again
+// when its services have drifted or the compose_file has changed.
+func resourceStackCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	for _, key := range []string{"name", "compose", "compose_file", "environment"} {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+
+	compose, err := getCompose(d)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(compose))
+	if hash := hex.EncodeToString(sum[:]); hash != d.Get("compose_sha256").(string) {
+		if err := d.SetNew("compose_sha256", hash); err != nil {
+			return err
+		}
+	}
+
+	services, err := composeServices(d.Get("name").(string), compose, d.Get("environment").(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+
+	current := d.Get("services").(map[string]interface{})
+
+	drifted := len(current) != len(services)
+	for name, image := range services {
+		if current[name] != image {
+			drifted = true
+		}
+	}
+
+	if drifted {
+		return d.SetNew("services", services)
+	}
+
+	return nil
+}
+
+// deployStack deploys the stack with `docker stack deploy`
+func deployStack(swarmManager *swarm.Manager, d *schema.ResourceData) error {
+	compose, err := getCompose(d)
+	if err != nil {
+		return err
+	}
+
+	flags := []string{}
+	if d.Get("prune").(bool) {
+		flags = append(flags, "--prune")
+	}
+	if d.Get("with_registry_auth").(bool) {
+		flags = append(flags, "--with-registry-auth")
+	}
+	flags = append(flags, shellQuote(d.Get("name").(string)))
+
+	cmd := fmt.Sprintf(stackDeployCommand, strings.Join(flags, " "))
+
+	// Variables are interpolated here rather than by docker so that their
+	// values are never on the command line of the manager. The rendered
+	// document is escaped so that docker renders it unchanged.
+	compose, err = interpolate(compose, d.Get("environment").(map[string]interface{}), true)
+	if err != nil {
+		return err
+	}
+
+	if _, err := runCmdWithInput(swarmManager, cmd, []byte(compose)); err != nil {
+		return fmt.Errorf("error running stack deploy command: %w", err)
+	}
+
+	return nil
+}
+
+func resourceStackCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	if err := deployStack(swarmManager, d); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to deploy swarm stack",
+			Detail:   fmt.Sprintf("Unable to deploy swarm stack %s: %s", d.Get("name").(string), err),
+		})
+		return diags
+	}
+
+	d.SetId(d.Get("name").(string))
+
+	return resourceStackRead(ctx, d, m)
+}
+
+func resourceStackRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	stackServices, err := getStackServices(swarmManager, d.Id())
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to retrieve swarm stack",
+			Detail:   fmt.Sprintf("Error listing services of swarm stack %s: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	// A stack only exists through its services
+	if len(stackServices) == 0 {
+		d.SetId("")
+		return diags
+	}
+
+	services := make(map[string]string)
+	for _, service := range stackServices {
+		services[service.Name] = normalizeImage(service.Image)
+	}
+
+	if err := d.Set("name", d.Id()); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("services", services); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+func resourceStackUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	if err := deployStack(swarmManager, d); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to update swarm stack",
+			Detail:   fmt.Sprintf("Unable to deploy swarm stack %s: %s", d.Get("name").(string), err),
+		})
+		return diags
+	}
+
+	return resourceStackRead(ctx, d, m)
+}
+
+func resourceStackDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	swarmManager := m.(*swarm.Manager)
+
+	if err := ensureManager(swarmManager); err != nil {
+		return diag.FromErr(fmt.Errorf("error connecting to manager node: %w", err))
+	}
+
+	if _, err := runCmd(swarmManager, fmt.Sprintf(stackRemoveCommand, shellQuote(d.Id()))); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Unable to remove swarm stack",
+			Detail:   fmt.Sprintf("Unable to remove swarm stack %s: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	d.SetId("")
+
+	return diags
+}
@@ -0,0 +1,91 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	environment := map[string]interface{}{
+		"TAG":   "1.21",
+		"EMPTY": "",
+		"PRICE": "$5",
+	}
+
+	testCases := []struct {
+		name     string
+		compose  string
+		expected string
+		escaped  string
+		err      bool
+	}{
+		{name: "named", compose: "nginx:$TAG", expected: "nginx:1.21"},
+		{name: "braced", compose: "nginx:${TAG}-alpine", expected: "nginx:1.21-alpine"},
+		{name: "unset", compose: "a${UNSET}b$UNSET", expected: "ab"},
+		{name: "default if unset or empty", compose: "${EMPTY:-x} ${UNSET:-y} ${TAG:-z}", expected: "x y 1.21"},
+		{name: "default if unset", compose: "${EMPTY-x} ${UNSET-y}", expected: " y"},
+		{name: "required", compose: "${TAG:?tag is required} ${EMPTY?set}", expected: "1.21 "},
+		{name: "required unset", compose: "${UNSET?tag is required}", err: true},
+		{name: "required empty", compose: "${EMPTY:?tag is required}", err: true},
+		{name: "alternative", compose: "${TAG:+set} ${EMPTY:+set} ${EMPTY+set} ${UNSET+set}", expected: "set  set "},
+		{name: "escaped", compose: "echo $$HOME", expected: "echo $HOME", escaped: "echo $$HOME"},
+		{name: "positional left as is", compose: "awk '{print $1}'", expected: "awk '{print $1}'", escaped: "awk '{print $$1}'"},
+		{name: "trailing dollar", compose: "cost: 5$", expected: "cost: 5$", escaped: "cost: 5$$"},
+		{name: "value with dollar", compose: "price: $PRICE", expected: "price: $5", escaped: "price: $$5"},
+		{name: "invalid braced", compose: "${1}", err: true},
+		{name: "invalid modifier", compose: "${TAG TAG}", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := interpolate(tc.compose, environment, false)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected error got %q", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %q got %q", tc.expected, actual)
+			}
+
+			escaped, err := interpolate(tc.compose, environment, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.escaped != "" && escaped != tc.escaped {
+				t.Errorf("expected escaped %q got %q", tc.escaped, escaped)
+			}
+
+			// docker renders the escaped document to the same result
+			rendered, err := interpolate(escaped, nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rendered != tc.expected {
+				t.Errorf("expected rendered %q got %q", tc.expected, rendered)
+			}
+		})
+	}
+}
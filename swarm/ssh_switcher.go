@@ -0,0 +1,872 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aucloud/go-runcmd"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/aucloud/go-swarm"
+)
+
+const (
+	defaultSSHPort = "22"
+
+	hostKeyPolicyStrict    = "strict"
+	hostKeyPolicyAcceptNew = "accept-new"
+	hostKeyPolicyInsecure  = "insecure"
+
+	// sshRetryInterval is how long to wait between connection attempts to
+	// nodes that are still booting
+	sshRetryInterval = time.Second * 2
+
+	// Keepalives are sent on every connection so that dropped connections
+	// are noticed and closed rather than hanging
+	sshKeepaliveRequest  = "keepalive@openssh.com"
+	sshKeepaliveInterval = time.Second * 5
+	sshKeepaliveTimeout  = time.Second * 15
+)
+
+// sshAuth are the credentials used to authenticate with ssh
+type sshAuth struct {
+	// key is private key material or, for compatibility, the path of one
+	key        string
+	keyFile    string
+	passphrase string
+
+	// agent uses the ssh-agent listening on SSH_AUTH_SOCK
+	agent bool
+}
+
+// bastionConfig is the jump host every node connection is tunnelled through
+type bastionConfig struct {
+	host string
+	port string
+	user string
+	key  string
+}
+
+// sshConfig is how the provider connects to swarm nodes over ssh
+type sshConfig struct {
+	user    string
+	port    string
+	auth    sshAuth
+	timeout time.Duration
+
+	// hostKeyPolicy decides how host keys of nodes without a pinned host
+	// key are verified against knownHostsFile
+	hostKeyPolicy  string
+	knownHostsFile string
+
+	// hostKeys are host keys or fingerprints pinned by node address for
+	// every connection, unless a node pins its own
+	hostKeys map[string]string
+
+	bastion *bastionConfig
+}
+
+// nodeSSHConfig overrides how the provider connects to a single node
+type nodeSSHConfig struct {
+	user string
+	port string
+	key  string
+
+	// address is dialled instead of the node address, e.g. behind NAT
+	address string
+
+	// hostKey is the pinned host key or fingerprint of the node
+	hostKey string
+}
+
+// hostKeyError is returned when the host key of a node cannot be verified
+type hostKeyError struct {
+	host        string
+	fingerprint string
+
+	// expected are the pinned or known host keys, none if the host is unknown
+	expected []string
+}
+
+func (e *hostKeyError) Error() string {
+	if len(e.expected) == 0 {
+		return fmt.Sprintf(
+			"error host key of %s with fingerprint %s is unknown, add it to the known_hosts file or set the node's host_key",
+			e.host, e.fingerprint,
+		)
+	}
+
+	return fmt.Sprintf(
+		"error host key of %s has fingerprint %s but expected %s, the node may have been reinstalled or the connection intercepted",
+		e.host, e.fingerprint, strings.Join(e.expected, " or "),
+	)
+}
+
+// sshSwitcher is a swarm.Switcher that connects to swarm nodes over ssh,
+// optionally through a bastion host.
+type sshSwitcher struct {
+	sync.RWMutex
+
+	config sshConfig
+
+	addr     string
+	user     string
+	nodeAddr string
+	runner   runcmd.Runner
+
+	// client is the connection to the current node and via the chain of
+	// node connections it was tunnelled through, if any, other than the
+	// bastion. Each connection of via is tunnelled through the previous.
+	client *ssh.Client
+	via    []*ssh.Client
+
+	bastion *ssh.Client
+
+	agent agent.ExtendedAgent
+
+	// nodes are the per node overrides by node address
+	nodes map[string]nodeSSHConfig
+}
+
+var _ swarm.Switcher = (*sshSwitcher)(nil)
+
+// newSSHSwitcher constructs a new Switcher that connects to remote Docker
+// nodes over ssh with the given configuration
+func newSSHSwitcher(config sshConfig) (*sshSwitcher, error) {
+	if config.port == "" {
+		config.port = defaultSSHPort
+	}
+
+	if config.bastion != nil && config.bastion.port == "" {
+		config.bastion.port = defaultSSHPort
+	}
+
+	if config.hostKeyPolicy == "" {
+		config.hostKeyPolicy = hostKeyPolicyAcceptNew
+	}
+
+	if config.hostKeyPolicy != hostKeyPolicyInsecure && config.knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("error finding default known_hosts file: %w", err)
+		}
+		config.knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	return &sshSwitcher{config: config, user: config.user, nodes: make(map[string]nodeSSHConfig)}, nil
+}
+
+// configureNodes sets the overrides of the nodes at the given addresses.
+// Pinned host keys are always verified regardless of policy.
+func (s *sshSwitcher) configureNodes(nodes map[string]nodeSSHConfig) {
+	s.Lock()
+	defer s.Unlock()
+
+	for addr, node := range nodes {
+		s.nodes[addr] = node
+	}
+}
+
+func (s *sshSwitcher) String() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	if s.config.bastion != nil {
+		return fmt.Sprintf("ssh://%s@%s (via %s)", s.user, s.addr, s.config.bastion.host)
+	}
+
+	return fmt.Sprintf("ssh://%s@%s", s.user, s.addr)
+}
+
+func (s *sshSwitcher) Runner() runcmd.Runner {
+	s.RLock()
+	defer s.RUnlock()
+	return s.runner
+}
+
+// Switch connects to the node at nodeAddr, through the bastion if one is
+// configured
+func (s *sshSwitcher) Switch(ctx context.Context, nodeAddr string) error {
+	jump, err := s.bastionClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.connect(ctx, nodeAddr, jump)
+}
+
+// SwitchVia connects to the node at nodeAddr by jumping through the current
+// node. Nodes are reached through the bastion instead if one is configured.
+func (s *sshSwitcher) SwitchVia(ctx context.Context, nodeAddr string) error {
+	jump, err := s.bastionClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if jump == nil {
+		s.RLock()
+		jump = s.client
+		s.RUnlock()
+	}
+
+	if jump == nil {
+		return fmt.Errorf("error not connected to any node to jump through to %s", nodeAddr)
+	}
+
+	return s.connect(ctx, nodeAddr, jump)
+}
+
+// reconnect connects to the current node again after its connection dead
+// was dropped, through the bastion or the node it was tunnelled through
+func (s *sshSwitcher) reconnect(dead *ssh.Client) error {
+	s.RLock()
+	nodeAddr, client, via := s.nodeAddr, s.client, s.via
+	s.RUnlock()
+
+	// Another command may have reconnected already
+	if client != dead {
+		return nil
+	}
+
+	ctx := context.Background()
+	if s.config.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.timeout)
+		defer cancel()
+	}
+
+	jump, err := s.bastionClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if jump == nil && len(via) > 0 {
+		jump = via[len(via)-1]
+	}
+
+	return s.connect(ctx, nodeAddr, jump)
+}
+
+// connect connects to the node at nodeAddr, tunnelling through jump unless
+// it is nil, and makes it the current node
+func (s *sshSwitcher) connect(ctx context.Context, nodeAddr string, jump *ssh.Client) error {
+	if _, ok := ctx.Deadline(); !ok && s.config.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.timeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(nodeAddr)
+	if err != nil {
+		host, port = nodeAddr, s.config.port
+	}
+
+	s.RLock()
+	node := s.nodes[host]
+	s.RUnlock()
+
+	hostKey := node.hostKey
+	if hostKey == "" {
+		hostKey = s.config.hostKeys[host]
+	}
+
+	user, auth := s.config.user, s.config.auth
+	if node.user != "" {
+		user = node.user
+	}
+	if node.key != "" {
+		auth.key, auth.keyFile = node.key, ""
+	}
+	if node.address != "" {
+		host = node.address
+	}
+	if node.port != "" {
+		port = node.port
+	}
+	addr := net.JoinHostPort(host, port)
+
+	config, err := s.clientConfig(user, auth, hostKey)
+	if err != nil {
+		return err
+	}
+
+	client, err := dialSSH(ctx, addr, config, jump)
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %w", addr, err)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// The connections the new node is tunnelled through are kept open
+	var via []*ssh.Client
+	if jump != nil && jump == s.client {
+		via = append(append([]*ssh.Client{}, s.via...), s.client)
+	} else {
+		for i, c := range s.via {
+			if c == jump {
+				via = s.via[:i+1]
+			}
+		}
+		for _, c := range s.via[len(via):] {
+			c.Close()
+		}
+		if s.client != nil {
+			s.client.Close()
+		}
+	}
+
+	s.addr = addr
+	s.user = user
+	s.nodeAddr = nodeAddr
+	s.client = client
+	s.via = via
+	s.runner = &sshRunner{switcher: s, client: client}
+
+	return nil
+}
+
+// bastionClient returns the connection to the bastion, connecting to it if
+// required, or nil if no bastion is configured
+func (s *sshSwitcher) bastionClient(ctx context.Context) (*ssh.Client, error) {
+	bastion := s.config.bastion
+	if bastion == nil {
+		return nil, nil
+	}
+
+	s.RLock()
+	client := s.bastion
+	s.RUnlock()
+
+	if client != nil {
+		if err := ping(client); err == nil {
+			return client, nil
+		}
+		// The bastion connection was dropped and is connected again
+		client.Close()
+	}
+
+	user, auth := bastion.user, s.config.auth
+	if user == "" {
+		user = s.config.user
+	}
+	if bastion.key != "" {
+		auth.key, auth.keyFile = bastion.key, ""
+	}
+
+	// The bastion is pinned by its address like any node
+	config, err := s.clientConfig(user, auth, s.config.hostKeys[bastion.host])
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(bastion.host, bastion.port)
+	client, err = dialSSH(ctx, addr, config, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to bastion %s: %w", addr, err)
+	}
+
+	s.Lock()
+	s.bastion = client
+	s.Unlock()
+
+	return client, nil
+}
+
+// clientConfig returns the ssh client configuration to authenticate as user
+// with the given credentials, verifying the pinned hostKey if any. Private
+// keys are tried before those of the ssh-agent.
+func (s *sshSwitcher) clientConfig(user string, auth sshAuth, hostKey string) (*ssh.ClientConfig, error) {
+	var signers []ssh.Signer
+
+	if auth.key != "" {
+		pemBytes := []byte(auth.key)
+		name := "ssh_key"
+
+		if !strings.Contains(auth.key, "PRIVATE KEY") {
+			path := os.ExpandEnv(auth.key)
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("error reading private ssh key %s: %w", path, err)
+			}
+			pemBytes, name = data, path
+		}
+
+		signer, err := parsePrivateKey(pemBytes, auth.passphrase, name)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, signer)
+	}
+
+	if auth.keyFile != "" {
+		path := os.ExpandEnv(auth.keyFile)
+
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading private ssh key %s: %w", path, err)
+		}
+
+		signer, err := parsePrivateKey(pemBytes, auth.passphrase, path)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, signer)
+	}
+
+	var sshAgent agent.ExtendedAgent
+	if auth.agent {
+		var err error
+		if sshAgent, err = s.agentClient(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(signers) == 0 && sshAgent == nil {
+		return nil, fmt.Errorf("error no ssh credentials, please configure ssh_key, ssh_key_file or ssh_agent")
+	}
+
+	publicKeys := func() ([]ssh.Signer, error) {
+		if sshAgent == nil {
+			return signers, nil
+		}
+
+		agentSigners, err := sshAgent.Signers()
+		if err != nil {
+			return nil, fmt.Errorf("error listing ssh-agent keys: %w", err)
+		}
+
+		return append(append([]ssh.Signer{}, signers...), agentSigners...), nil
+	}
+
+	verifyHostKey := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return s.verifyHostKey(hostname, remote, key, hostKey)
+	}
+
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeysCallback(publicKeys)},
+		HostKeyCallback: verifyHostKey,
+		Timeout:         s.config.timeout,
+	}, nil
+}
+
+// verifyHostKey verifies the host key of a node against its pinned host key
+// or, depending on the host key policy, the known_hosts file
+func (s *sshSwitcher) verifyHostKey(hostname string, remote net.Addr, key ssh.PublicKey, pinned string) error {
+	host, _, err := net.SplitHostPort(hostname)
+	if err != nil {
+		host = hostname
+	}
+
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	pinned = strings.TrimSpace(pinned)
+	if pinned != "" {
+		if !matchesHostKey(pinned, key) {
+			return &hostKeyError{host: host, fingerprint: fingerprint, expected: []string{pinned}}
+		}
+		return nil
+	}
+
+	if s.config.hostKeyPolicy == hostKeyPolicyInsecure {
+		return nil
+	}
+
+	path := os.ExpandEnv(s.config.knownHostsFile)
+
+	if s.config.hostKeyPolicy == hostKeyPolicyAcceptNew {
+		// knownhosts requires the file to exist
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("error creating known_hosts directory %s: %w", filepath.Dir(path), err)
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("error creating known_hosts file %s: %w", path, err)
+		}
+		f.Close()
+	}
+
+	// Every host is unknown without a known_hosts file
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return &hostKeyError{host: host, fingerprint: fingerprint}
+	}
+
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return fmt.Errorf("error reading known_hosts file %s: %w", path, err)
+	}
+
+	err = callback(hostname, remote, key)
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+
+	if len(keyErr.Want) == 0 && s.config.hostKeyPolicy == hostKeyPolicyAcceptNew {
+		return appendKnownHost(path, hostname, key)
+	}
+
+	expected := make([]string, len(keyErr.Want))
+	for i, want := range keyErr.Want {
+		expected[i] = fmt.Sprintf("%s (%s:%d)", ssh.FingerprintSHA256(want.Key), want.Filename, want.Line)
+	}
+
+	return &hostKeyError{host: host, fingerprint: fingerprint, expected: expected}
+}
+
+// matchesHostKey returns true if key is the pinned host key given in
+// authorized_keys format or as a SHA256 or MD5 fingerprint
+func matchesHostKey(pinned string, key ssh.PublicKey) bool {
+	switch {
+	case strings.HasPrefix(pinned, "SHA256:"):
+		return pinned == ssh.FingerprintSHA256(key)
+	case strings.HasPrefix(pinned, "MD5:"):
+		return strings.TrimPrefix(pinned, "MD5:") == ssh.FingerprintLegacyMD5(key)
+	}
+
+	expected, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pinned))
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(expected.Marshal(), key.Marshal())
+}
+
+// appendKnownHost adds the host key of hostname to the known_hosts file
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening known_hosts file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("error writing known_hosts file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// agentClient returns the ssh-agent listening on SSH_AUTH_SOCK, connecting
+// to it if required
+func (s *sshSwitcher) agentClient() (agent.ExtendedAgent, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.agent != nil {
+		return s.agent, nil
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("error ssh_agent is enabled but SSH_AUTH_SOCK is not set")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to ssh-agent %s: %w", socket, err)
+	}
+
+	s.agent = agent.NewClient(conn)
+
+	return s.agent, nil
+}
+
+// parsePrivateKey parses the private key name, decrypting it with
+// passphrase if given
+func parsePrivateKey(pemBytes []byte, passphrase, name string) (ssh.Signer, error) {
+	var (
+		signer ssh.Signer
+		err    error
+	)
+
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(pemBytes)
+	}
+
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("error private ssh key %s is encrypted, please configure ssh_key_passphrase", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private ssh key %s: %w", name, err)
+	}
+
+	return signer, nil
+}
+
+// dialSSH connects to addr, tunnelling through jump unless it is nil.
+// Connecting is retried until ctx expires as nodes may still be booting but
+// failing the ssh handshake, such as being unable to authenticate, is not.
+// The connection is kept alive until it is closed.
+func dialSSH(ctx context.Context, addr string, config *ssh.ClientConfig, jump *ssh.Client) (*ssh.Client, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	for {
+		if jump != nil {
+			conn, err = dialJump(ctx, jump, addr)
+		} else {
+			dialer := net.Dialer{Timeout: config.Timeout}
+			conn, err = dialer.DialContext(ctx, "tcp", addr)
+		}
+		if err == nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(sshRetryInterval):
+		}
+	}
+
+	// Connections tunnelled through jump do not support deadlines so the
+	// handshake is also aborted by closing the connection
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	type handshake struct {
+		conn  ssh.Conn
+		chans <-chan ssh.NewChannel
+		reqs  <-chan *ssh.Request
+		err   error
+	}
+
+	// The handshake only keeps the message of a failed host key verification
+	// so its error is kept for callers to tell it apart
+	var hostKeyErr error
+	verified := *config
+	verified.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		hostKeyErr = config.HostKeyCallback(hostname, remote, key)
+		return hostKeyErr
+	}
+
+	done := make(chan handshake, 1)
+	go func() {
+		c, chans, reqs, err := ssh.NewClientConn(conn, addr, &verified)
+		done <- handshake{c, chans, reqs, err}
+	}()
+
+	var h handshake
+	select {
+	case h = <-done:
+	case <-ctx.Done():
+		conn.Close()
+		<-done
+		return nil, fmt.Errorf("error ssh handshake: %w", ctx.Err())
+	}
+
+	if h.err != nil {
+		conn.Close()
+		if hostKeyErr != nil {
+			return nil, fmt.Errorf("error ssh handshake: %w", hostKeyErr)
+		}
+		return nil, h.err
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	client := ssh.NewClient(h.conn, h.chans, h.reqs)
+	go keepAlive(client)
+
+	return client, nil
+}
+
+// dialJump dials addr through the jump connection until ctx expires
+func dialJump(ctx context.Context, jump *ssh.Client, addr string) (net.Conn, error) {
+	type dial struct {
+		conn net.Conn
+		err  error
+	}
+
+	done := make(chan dial, 1)
+	go func() {
+		conn, err := jump.Dial("tcp", addr)
+		done <- dial{conn, err}
+	}()
+
+	select {
+	case d := <-done:
+		return d.conn, d.err
+	case <-ctx.Done():
+		// The connection is closed if it is established after all
+		go func() {
+			if d := <-done; d.conn != nil {
+				d.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// ping sends a keepalive on the connection and waits for the reply
+func ping(client *ssh.Client) error {
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest(sshKeepaliveRequest, true, nil)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(sshKeepaliveTimeout):
+		return fmt.Errorf("error no reply to keepalive after %s", sshKeepaliveTimeout)
+	}
+}
+
+// keepAlive pings the connection until it is closed and closes it once it
+// stops replying
+func keepAlive(client *ssh.Client) {
+	closed := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(sshKeepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			if err := ping(client); err != nil {
+				client.Close()
+				return
+			}
+		}
+	}
+}
+
+// sshRunner is a runcmd.Runner that runs commands over an ssh connection,
+// reconnecting once if the connection was dropped
+type sshRunner struct {
+	switcher *sshSwitcher
+	client   *ssh.Client
+}
+
+func (r *sshRunner) Command(cmdline string) (runcmd.CmdWorker, error) {
+	if cmdline == "" {
+		return nil, fmt.Errorf("error command cannot be empty")
+	}
+
+	session, err := r.client.NewSession()
+	if err != nil {
+		if err := r.switcher.reconnect(r.client); err != nil {
+			return nil, fmt.Errorf("error reconnecting: %w", err)
+		}
+
+		r.switcher.RLock()
+		client := r.switcher.client
+		r.switcher.RUnlock()
+
+		session, err = client.NewSession()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &sshCmd{cmdline: cmdline, session: session}, nil
+}
+
+// sshCmd is a runcmd.CmdWorker running a command in an ssh session
+type sshCmd struct {
+	cmdline string
+	session *ssh.Session
+}
+
+func (c *sshCmd) Run() ([]string, error) {
+	var buffer bytes.Buffer
+
+	c.SetStdout(&buffer)
+	c.SetStderr(&buffer)
+
+	if err := c.Start(); err != nil {
+		c.session.Close()
+		return nil, err
+	}
+
+	err := c.Wait()
+	output := strings.Split(buffer.String(), "\n")
+
+	if err != nil {
+		return nil, runcmd.ExecError{ExecutionError: err, CommandLine: c.cmdline, Output: output}
+	}
+
+	return output, nil
+}
+
+func (c *sshCmd) Start() error {
+	return c.session.Start(c.cmdline)
+}
+
+func (c *sshCmd) Wait() error {
+	defer c.session.Close()
+	return c.session.Wait()
+}
+
+func (c *sshCmd) StdinPipe() (io.WriteCloser, error) {
+	return c.session.StdinPipe()
+}
+
+func (c *sshCmd) StdoutPipe() (io.Reader, error) {
+	return c.session.StdoutPipe()
+}
+
+func (c *sshCmd) StderrPipe() (io.Reader, error) {
+	return c.session.StderrPipe()
+}
+
+func (c *sshCmd) SetStdout(w io.Writer) {
+	c.session.Stdout = w
+}
+
+func (c *sshCmd) SetStderr(w io.Writer) {
+	c.session.Stderr = w
+}
+
+func (c *sshCmd) GetCommandLine() string {
+	return c.cmdline
+}
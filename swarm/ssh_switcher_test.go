@@ -0,0 +1,231 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestMatchesHostKey(t *testing.T) {
+	key, other := testHostKey(t), testHostKey(t)
+
+	testCases := []struct {
+		name     string
+		pinned   string
+		expected bool
+	}{
+		{name: "sha256", pinned: ssh.FingerprintSHA256(key), expected: true},
+		{name: "md5", pinned: "MD5:" + ssh.FingerprintLegacyMD5(key), expected: true},
+		{name: "authorized key", pinned: string(ssh.MarshalAuthorizedKey(key)), expected: true},
+		{name: "authorized key with comment", pinned: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))) + " root@manager1", expected: true},
+		{name: "other sha256", pinned: ssh.FingerprintSHA256(other)},
+		{name: "other authorized key", pinned: string(ssh.MarshalAuthorizedKey(other))},
+		{name: "garbage", pinned: "not a key"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := matchesHostKey(tc.pinned, key); actual != tc.expected {
+				t.Errorf("expected %t got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestVerifyHostKey(t *testing.T) {
+	key, other := testHostKey(t), testHostKey(t)
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+
+	t.Run("accept-new creates the known_hosts file", func(t *testing.T) {
+		s, err := newSSHSwitcher(sshConfig{
+			hostKeyPolicy:  hostKeyPolicyAcceptNew,
+			knownHostsFile: filepath.Join(t.TempDir(), ".ssh", "known_hosts"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.verifyHostKey("10.0.0.1:22", remote, key, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.verifyHostKey("10.0.0.1:22", remote, key, ""); err != nil {
+			t.Fatalf("expected the accepted key to be known got %s", err)
+		}
+
+		var hostKeyErr *hostKeyError
+		if err := s.verifyHostKey("10.0.0.1:22", remote, other, ""); !errors.As(err, &hostKeyErr) {
+			t.Fatalf("expected a host key error got %v", err)
+		}
+	})
+
+	t.Run("strict rejects unknown hosts", func(t *testing.T) {
+		s, err := newSSHSwitcher(sshConfig{
+			hostKeyPolicy:  hostKeyPolicyStrict,
+			knownHostsFile: filepath.Join(t.TempDir(), "known_hosts"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var hostKeyErr *hostKeyError
+		if err := s.verifyHostKey("10.0.0.1:22", remote, key, ""); !errors.As(err, &hostKeyErr) {
+			t.Fatalf("expected a host key error got %v", err)
+		}
+
+		if err := s.verifyHostKey("10.0.0.1:22", remote, key, ssh.FingerprintSHA256(key)); err != nil {
+			t.Fatalf("expected the pinned key to be accepted got %s", err)
+		}
+	})
+}
+
+// testSSHServer accepts ssh connections on a local port with the given host
+// key until the test ends and returns its address
+func testSSHServer(t *testing.T, hostKey ssh.Signer) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostKey)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for ch := range chans {
+					ch.Reject(ssh.Prohibited, "no channels")
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func testSigner(t *testing.T) (ssh.Signer, string) {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func TestBastionHostKey(t *testing.T) {
+	hostKey, _ := testSigner(t)
+	_, clientKey := testSigner(t)
+
+	host, port, err := net.SplitHostPort(testSSHServer(t, hostKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name   string
+		pinned string
+		valid  bool
+	}{
+		{name: "pinned", pinned: ssh.FingerprintSHA256(hostKey.PublicKey()), valid: true},
+		{name: "wrong pin", pinned: ssh.FingerprintSHA256(testHostKey(t))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := newSSHSwitcher(sshConfig{
+				user:          "terraform",
+				auth:          sshAuth{key: clientKey},
+				timeout:       time.Second * 5,
+				hostKeyPolicy: hostKeyPolicyInsecure,
+				hostKeys:      map[string]string{host: tc.pinned},
+				bastion:       &bastionConfig{host: host, port: port},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client, err := s.bastionClient(context.Background())
+			if tc.valid {
+				if err != nil {
+					t.Fatalf("expected to connect to the bastion got %s", err)
+				}
+				client.Close()
+				return
+			}
+
+			var hostKeyErr *hostKeyError
+			if !errors.As(err, &hostKeyErr) {
+				t.Fatalf("expected a host key error got %v", err)
+			}
+		})
+	}
+}
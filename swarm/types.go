@@ -0,0 +1,271 @@
+/*
+	terraform-provider-swarm is a Terraform provider for the creation and management of
+	Docker Swarm clusters (an alternative container orchestrator to Kubernetes and Nomad)
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"github.com/aucloud/go-swarm"
+)
+
+const (
+	nodeStateDown = "down"
+
+	localNodeStateLocked = "locked"
+)
+
+// NodeSpec is the user modifiable part of a swarm node
+type NodeSpec struct {
+	Role         string
+	Availability string
+	Labels       map[string]string
+}
+
+// NodeDescription is the description of a swarm node as reported by its engine
+type NodeDescription struct {
+	Hostname string
+	Platform struct {
+		Architecture string
+		OS           string
+	}
+	Resources struct {
+		NanoCPUs    int64
+		MemoryBytes int64
+	}
+	Engine struct {
+		EngineVersion string
+		Labels        map[string]string
+	}
+	TLSInfo TLSInfo
+}
+
+// TLSInfo is the TLS trust root of a swarm node or cluster
+type TLSInfo struct {
+	TrustRoot string
+}
+
+// ManagerStatus is the raft status of a swarm manager node
+type ManagerStatus struct {
+	Leader       bool
+	Reachability string
+	Addr         string
+}
+
+// Node is a swarm node as returned by `docker node inspect`
+type Node struct {
+	ID          string
+	Spec        NodeSpec
+	Description NodeDescription
+	Status      struct {
+		State string
+		Addr  string
+	}
+	ManagerStatus *ManagerStatus
+}
+
+// IsManager returns true if the node has the manager role
+func (node Node) IsManager() bool {
+	return node.Spec.Role == swarm.ManagerRole
+}
+
+// Mount is a mount of a service's containers
+type Mount struct {
+	Type     string
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// FileReference is a secret or config mounted into a service's containers
+type FileReference struct {
+	File struct {
+		Name string
+		UID  string
+		GID  string
+		Mode uint32
+	}
+	SecretName string
+	ConfigName string
+}
+
+// HealthConfig is the healthcheck of a service's containers
+type HealthConfig struct {
+	Test        []string
+	Interval    int64
+	Timeout     int64
+	StartPeriod int64
+	Retries     int
+}
+
+// Resources are the CPU and memory resources of a service's tasks
+type Resources struct {
+	NanoCPUs    int64
+	MemoryBytes int64
+}
+
+// UpdateConfig is the update or rollback strategy of a service
+type UpdateConfig struct {
+	Parallelism     int
+	Delay           int64
+	FailureAction   string
+	Monitor         int64
+	MaxFailureRatio float64
+	Order           string
+}
+
+// PortConfig is a port published by a service
+type PortConfig struct {
+	Protocol      string
+	TargetPort    int
+	PublishedPort int
+	PublishMode   string
+}
+
+// ServiceSpec is the user modifiable part of a swarm service
+type ServiceSpec struct {
+	Name         string
+	Labels       map[string]string
+	TaskTemplate struct {
+		ContainerSpec struct {
+			Image       string
+			Args        []string
+			Env         []string
+			Mounts      []Mount
+			Secrets     []FileReference
+			Configs     []FileReference
+			Healthcheck *HealthConfig
+		}
+		Resources *struct {
+			Limits       *Resources
+			Reservations *Resources
+		}
+		Placement *struct {
+			Constraints []string
+			Preferences []struct {
+				Spread struct {
+					SpreadDescriptor string
+				}
+			}
+		}
+		Networks []struct {
+			Target string
+		}
+	}
+	Mode struct {
+		Replicated *struct {
+			Replicas int
+		}
+		Global *struct{}
+	}
+	UpdateConfig   *UpdateConfig
+	RollbackConfig *UpdateConfig
+	EndpointSpec   *struct {
+		Ports []PortConfig
+	}
+}
+
+// Service is a swarm service as returned by `docker service inspect`
+type Service struct {
+	ID   string
+	Spec ServiceSpec
+}
+
+// IPAMConfig is a subnet of a network
+type IPAMConfig struct {
+	Subnet  string
+	Gateway string
+	IPRange string
+}
+
+// Network is a network as returned by `docker network inspect`
+type Network struct {
+	ID         string
+	Name       string
+	Driver     string
+	Scope      string
+	Internal   bool
+	Attachable bool
+	Ingress    bool
+	IPAM       struct {
+		Config []IPAMConfig
+	}
+	Options map[string]string
+	Labels  map[string]string
+}
+
+// Object is a secret or config as returned by `docker secret inspect` or
+// `docker config inspect`. The data of secrets is never returned.
+type Object struct {
+	ID   string
+	Spec struct {
+		Name       string
+		Labels     map[string]string
+		Data       []byte
+		Templating *struct {
+			Name string
+		}
+	}
+}
+
+// StackService is a service of a stack as returned by `docker stack services`
+type StackService struct {
+	ID       string
+	Name     string
+	Image    string
+	Mode     string
+	Replicas string
+}
+
+// ExternalCA is a certificate authority that issues node certificates
+type ExternalCA struct {
+	Protocol string
+	URL      string
+}
+
+// ClusterSpec is the user modifiable part of a swarm cluster
+type ClusterSpec struct {
+	Orchestration struct {
+		TaskHistoryRetentionLimit *int64
+	}
+	Raft struct {
+		SnapshotInterval           uint64
+		KeepOldSnapshots           *uint64
+		LogEntriesForSlowFollowers uint64
+	}
+	Dispatcher struct {
+		HeartbeatPeriod int64
+	}
+	CAConfig struct {
+		NodeCertExpiry int64
+		ExternalCAs    []ExternalCA
+	}
+	EncryptionConfig struct {
+		AutoLockManagers bool
+	}
+}
+
+// Cluster is a swarm cluster as returned by `docker info`
+type Cluster struct {
+	ID                     string
+	Spec                   ClusterSpec
+	TLSInfo                TLSInfo
+	RootRotationInProgress bool
+	DefaultAddrPool        []string
+	SubnetSize             uint32
+	DataPathPort           uint32
+}